@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audio backend names accepted in Config.AudioBackend
+const (
+	BackendPortAudio = "portaudio"
+	BackendFile      = "file"
+	BackendNull      = "null"
+)
+
+// StreamConfig describes the stream requested from a backend
+type StreamConfig struct {
+	DeviceID        string // Device ID from AudioDeviceInfo, empty for default
+	SampleRate      int    // Requested sample rate in Hz
+	Channels        int    // Requested channel count
+	FramesPerBuffer int    // Frames handed to the callback per cycle
+}
+
+// AudioStream is an open input or output stream
+type AudioStream interface {
+	Start() error
+	Stop() error
+	Close() error
+
+	// Actual stream format, which may differ from the requested one
+	SampleRate() int
+	Channels() int
+}
+
+// AudioBackend abstracts the audio system used for recording and playback.
+// Input is delivered as float32 samples in [-1, 1] so recordings can keep
+// more than 16 bits of resolution.
+type AudioBackend interface {
+	Name() string
+	Devices() ([]AudioDeviceInfo, error)
+	OpenInputStream(cfg StreamConfig, process func(in []float32)) (AudioStream, error)
+	OpenOutputStream(cfg StreamConfig, process func(out []int16)) (AudioStream, error)
+}
+
+// Create the audio backend selected in the config.
+// VOICELOG_AUDIO_BACKEND overrides the config, e.g. on CI machines.
+func newAudioBackend(config Config) AudioBackend {
+	name := config.AudioBackend
+	if env := os.Getenv("VOICELOG_AUDIO_BACKEND"); env != "" {
+		name = env
+	}
+
+	switch name {
+	case BackendFile:
+		return newFileBackend(config.BackendInputFile, config.BackendOutputFile)
+	case BackendNull:
+		return newNullBackend()
+	case BackendPortAudio, "":
+		return newPortAudioBackend()
+	default:
+		log.Printf("Unknown audio backend %q, using %s", name, BackendPortAudio)
+		return newPortAudioBackend()
+	}
+}
+
+// Fill in defaults for fields a caller left empty
+func (c StreamConfig) withDefaults() StreamConfig {
+	if c.SampleRate <= 0 {
+		c.SampleRate = SampleRate
+	}
+	if c.Channels <= 0 {
+		c.Channels = ChannelCount
+	}
+	if c.FramesPerBuffer <= 0 {
+		c.FramesPerBuffer = 1024
+	}
+	return c
+}
+
+// pacedStream drives a callback from a goroutine at the rate a real device
+// would, for backends that have no hardware clock behind them
+type pacedStream struct {
+	sampleRate int
+	channels   int
+	frames     int
+	cycle      func()       // Called once per buffer period
+	onClose    func() error // Releases backend resources
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newPacedStream(cfg StreamConfig, cycle func(), onClose func() error) *pacedStream {
+	return &pacedStream{
+		sampleRate: cfg.SampleRate,
+		channels:   cfg.Channels,
+		frames:     cfg.FramesPerBuffer,
+		cycle:      cycle,
+		onClose:    onClose,
+	}
+}
+
+func (s *pacedStream) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+	return nil
+}
+
+func (s *pacedStream) run(stop, done chan struct{}) {
+	defer close(done)
+
+	period := time.Duration(float64(s.frames) / float64(s.sampleRate) * float64(time.Second))
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.cycle()
+		}
+	}
+}
+
+func (s *pacedStream) Stop() error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	close(s.stop)
+	done := s.done
+	s.mu.Unlock()
+
+	// Wait for the in-flight callback so no buffer is delivered after Stop
+	<-done
+	return nil
+}
+
+func (s *pacedStream) Close() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	if s.onClose != nil {
+		return s.onClose()
+	}
+	return nil
+}
+
+func (s *pacedStream) SampleRate() int { return s.sampleRate }
+func (s *pacedStream) Channels() int   { return s.channels }
+
+// nullBackend records silence and discards playback
+type nullBackend struct{}
+
+func newNullBackend() AudioBackend {
+	return nullBackend{}
+}
+
+func (nullBackend) Name() string { return BackendNull }
+
+func (nullBackend) Devices() ([]AudioDeviceInfo, error) {
+	return []AudioDeviceInfo{{
+		ID:        "null",
+		Name:      "Null Device (silence)",
+		IsDefault: true,
+		IsInput:   true,
+		IsOutput:  true,
+	}}, nil
+}
+
+func (nullBackend) OpenInputStream(cfg StreamConfig, process func(in []float32)) (AudioStream, error) {
+	cfg = cfg.withDefaults()
+	buf := make([]float32, cfg.FramesPerBuffer*cfg.Channels)
+	return newPacedStream(cfg, func() {
+		for i := range buf {
+			buf[i] = 0
+		}
+		process(buf)
+	}, nil), nil
+}
+
+func (nullBackend) OpenOutputStream(cfg StreamConfig, process func(out []int16)) (AudioStream, error) {
+	cfg = cfg.withDefaults()
+	buf := make([]int16, cfg.FramesPerBuffer*cfg.Channels)
+	return newPacedStream(cfg, func() { process(buf) }, nil), nil
+}
+
+// fileBackend reads recordings from an audio file and writes playback to a
+// WAV file, so the record → save → play flow runs without audio hardware
+type fileBackend struct {
+	inputPath  string
+	outputPath string
+}
+
+func newFileBackend(inputPath, outputPath string) AudioBackend {
+	return fileBackend{inputPath: inputPath, outputPath: outputPath}
+}
+
+func (b fileBackend) Name() string { return BackendFile }
+
+func (b fileBackend) Devices() ([]AudioDeviceInfo, error) {
+	var devices []AudioDeviceInfo
+	if b.inputPath != "" {
+		devices = append(devices, AudioDeviceInfo{
+			ID:        "file-in",
+			Name:      fmt.Sprintf("Audio File (%s)", filepath.Base(b.inputPath)),
+			IsDefault: true,
+			IsInput:   true,
+		})
+	}
+	outputName := "Discard"
+	if b.outputPath != "" {
+		outputName = filepath.Base(b.outputPath)
+	}
+	devices = append(devices, AudioDeviceInfo{
+		ID:        "file-out",
+		Name:      fmt.Sprintf("WAV File (%s)", outputName),
+		IsDefault: true,
+		IsOutput:  true,
+	})
+	return devices, nil
+}
+
+// Open the input file and play its samples into the callback at the file's
+// own sample rate, converted to the requested channel count. Once the file
+// is exhausted the stream delivers silence.
+func (b fileBackend) OpenInputStream(cfg StreamConfig, process func(in []float32)) (AudioStream, error) {
+	if b.inputPath == "" {
+		return nil, fmt.Errorf("file backend: no input file configured")
+	}
+
+	samples, sampleRate, channels, err := readAudioData(b.inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("file backend: %w", err)
+	}
+	if channels <= 0 {
+		return nil, fmt.Errorf("file backend: invalid channel count %d", channels)
+	}
+
+	cfg = cfg.withDefaults()
+	cfg.SampleRate = sampleRate
+
+	pos := 0 // Frame position in the source file
+	frames := len(samples) / channels
+	log.Printf("File backend input: %s (%d Hz, %d channels, %d frames)",
+		b.inputPath, sampleRate, channels, frames)
+
+	buf := make([]float32, cfg.FramesPerBuffer*cfg.Channels)
+	return newPacedStream(cfg, func() {
+		for i := 0; i < len(buf)/cfg.Channels; i++ {
+			for ch := 0; ch < cfg.Channels; ch++ {
+				var sample float32
+				if pos < frames {
+					sample = float32(samples[pos*channels+min(ch, channels-1)]) / 32768
+				}
+				buf[i*cfg.Channels+ch] = sample
+			}
+			if pos < frames {
+				pos++
+			}
+		}
+		process(buf)
+	}, nil), nil
+}
+
+// Open the output file and write whatever the callback produces as 16-bit
+// PCM. Without an output path the audio is discarded.
+func (b fileBackend) OpenOutputStream(cfg StreamConfig, process func(out []int16)) (AudioStream, error) {
+	cfg = cfg.withDefaults()
+	buf := make([]int16, cfg.FramesPerBuffer*cfg.Channels)
+	if b.outputPath == "" {
+		return newPacedStream(cfg, func() { process(buf) }, nil), nil
+	}
+
+	file, err := os.Create(b.outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("file backend: %w", err)
+	}
+	if err := writeWAVHeader(file, cfg.SampleRate, cfg.Channels, 16, 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("file backend: %w", err)
+	}
+
+	return newPacedStream(cfg, func() {
+		process(buf)
+		if err := binary.Write(file, binary.LittleEndian, buf); err != nil {
+			log.Printf("File backend: error writing output: %v", err)
+		}
+	}, func() error {
+		if err := finalizeWAVHeader(file); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// Largest difference between the first channel of a recording and a mono
+// source, over the frames they share
+func maxDifference(recorded []float32, channels int, source []float32) float64 {
+	var diff float64
+	for i := 0; i < len(recorded)/channels && i < len(source); i++ {
+		diff = math.Max(diff, math.Abs(float64(recorded[i*channels]-source[i])))
+	}
+	return diff
+}
+
+// Record from a fixture through the file backend, save the memo, then play
+// it back to a WAV file and through the null backend
+func TestRecordAndPlay(t *testing.T) {
+	input := writeToneWAV(t)
+	output := filepath.Join(t.TempDir(), "played.wav")
+	fileBackendHome(t, input, output)
+	source, _, err := readWAVFloat(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := runCommand([]string{"record", "--duration", "1s", "--name", "Tone", "--json"}, &stdout, &stderr); status != 0 {
+		t.Fatalf("record exited with %d: %s", status, stderr.String())
+	}
+	var memo memoOutput
+	if err := json.Unmarshal(stdout.Bytes(), &memo); err != nil {
+		t.Fatalf("record output: %v\n%s", err, stdout.String())
+	}
+	if memo.Name != "Tone" || memo.Format != FormatWAV.String() || memo.Duration < 0.9 || memo.Duration > 1.2 {
+		t.Errorf("recorded memo = %+v", memo.Memo)
+	}
+
+	recorded, info, err := readWAVFloat(memo.Path)
+	if err != nil {
+		t.Fatalf("reading the recording: %v", err)
+	}
+	if info.SampleRate != 44100 {
+		t.Errorf("recorded at %d Hz, want the fixture's 44100 Hz", info.SampleRate)
+	}
+	if diff := maxDifference(recorded, info.Channels, source); diff > 1e-3 {
+		t.Errorf("recording differs from the fixture by up to %.4f", diff)
+	}
+
+	// The saved memo is listed and plays back to the output file unchanged
+	stdout.Reset()
+	if status := runCommand([]string{"play", memo.ID, "--json"}, &stdout, &stderr); status != 0 {
+		t.Fatalf("play exited with %d: %s", status, stderr.String())
+	}
+	played, playedInfo, err := readWAVFloat(output)
+	if err != nil {
+		t.Fatalf("reading the playback: %v", err)
+	}
+	if frames := len(played) / playedInfo.Channels; frames < len(recorded)/info.Channels {
+		t.Errorf("played %d frames of %d", frames, len(recorded)/info.Channels)
+	}
+	// The last buffer is padded with silence past the end of the memo
+	if diff := maxDifference(played, playedInfo.Channels, source[:len(recorded)/info.Channels]); diff > 1e-3 {
+		t.Errorf("playback differs from the fixture by up to %.4f", diff)
+	}
+
+	t.Setenv("VOICELOG_AUDIO_BACKEND", BackendNull)
+	if status := runCommand([]string{"play", memo.ID, "--json"}, &stdout, &stderr); status != 0 {
+		t.Fatalf("play through the null backend exited with %d: %s", status, stderr.String())
+	}
+}
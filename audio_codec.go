@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	shine "github.com/braheezy/shine-mp3/pkg/mp3"
+	gomp3 "github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// Suffix of the PCM capture a compressed memo is recorded into before it
+// is encoded, e.g. memo_2025-01-02_15-04-05.capture.wav
+const captureSuffix = ".capture.wav"
+
+// MP3 bitrate settings
+const (
+	DefaultMP3Bitrate = 128 // kbps
+)
+
+// Layer III bitrates in kbps, indexed by the frame header bitrate index.
+// MPEG-2.5 uses the first entries of the MPEG-2 table, up to 64 kbps.
+var (
+	mp3BitratesMPEG1 = []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mp3BitratesMPEG2 = []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+)
+
+// The bitrate table of the MPEG version used at a sample rate: MPEG-1
+// from 32 kHz, MPEG-2 from 16 kHz and MPEG-2.5 below
+func mp3Bitrates(sampleRate int) []int {
+	switch {
+	case sampleRate >= 32000:
+		return mp3BitratesMPEG1
+	case sampleRate >= 16000:
+		return mp3BitratesMPEG2
+	default:
+		return mp3BitratesMPEG2[:9]
+	}
+}
+
+// The valid bitrate closest to bitrate at a sample rate, the lower one on
+// a tie
+func nearestMP3Bitrate(sampleRate, bitrate int) int {
+	nearest := 0
+	for _, rate := range mp3Bitrates(sampleRate)[1:] {
+		if nearest == 0 || abs(rate-bitrate) < abs(nearest-bitrate) {
+			nearest = rate
+		}
+	}
+	return nearest
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Path of the PCM capture for a memo that will be encoded to filePath
+func capturePath(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + captureSuffix
+}
+
+// Read interleaved 16-bit samples from any supported audio file
+func readAudioData(filePath string) ([]int16, int, int, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case FormatMP3.Extension():
+		return readMP3Data(filePath)
+	case FormatOGG.Extension():
+		return readOggData(filePath)
+	default:
+		return readWAVData(filePath)
+	}
+}
+
+// Read any supported audio file as interleaved samples in [-1, 1], along
+// with the sample size to write them back at. WAV files keep their own
+// precision; compressed formats decode to 16 bits.
+func readAudioFloat(filePath string) ([]float32, int, int, int, error) {
+	if strings.ToLower(filepath.Ext(filePath)) == FormatWAV.Extension() {
+		samples, info, err := readWAVFloat(filePath)
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		return samples, info.SampleRate, info.Channels, info.outputBits(), nil
+	}
+
+	samples, sampleRate, channels, err := readAudioData(filePath)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	out := make([]float32, len(samples))
+	for i, s := range samples {
+		out[i] = float32(s) / 32768
+	}
+	return out, sampleRate, channels, 16, nil
+}
+
+// Encode a finished PCM WAV capture into the given format
+func encodeAudioFile(srcPath, dstPath string, format AudioFormat, config Config) error {
+	switch format {
+	case FormatMP3:
+		return encodeMP3File(srcPath, dstPath, config.MP3Bitrate)
+	case FormatOGG:
+		return encodeOggFile(srcPath, dstPath, config.OggBitrate)
+	default:
+		return fmt.Errorf("encoding to %s is not supported", format)
+	}
+}
+
+// Encode a WAV file to MP3 at the given bitrate (kbps)
+func encodeMP3File(srcPath, dstPath string, bitrate int) error {
+	samples, sampleRate, channels, err := readWAVData(srcPath)
+	if err != nil {
+		return err
+	}
+	if channels < 1 || channels > 2 {
+		return fmt.Errorf("MP3 supports 1 or 2 channels, got %d", channels)
+	}
+
+	// The sample rate may have changed since the bitrate was checked
+	if valid := nearestMP3Bitrate(sampleRate, bitrate); valid != bitrate {
+		log.Printf("MP3 bitrate %d kbps is not valid at %d Hz, using %d kbps", bitrate, sampleRate, valid)
+		bitrate = valid
+	}
+	if _, err := shine.CheckConfig(sampleRate, bitrate); err != nil {
+		return fmt.Errorf("MP3 encoding at %d Hz, %d kbps: %w", sampleRate, bitrate, err)
+	}
+
+	// shine lays out MPEG-1 mono frames with the MPEG-2 side info size,
+	// which corrupts the stream, so MPEG-1 rates are always encoded as stereo
+	if channels == 1 && sampleRate >= 32000 {
+		samples = monoToStereo(samples)
+		channels = 2
+	}
+
+	return writeFileAtomic(dstPath, func(file *os.File) error {
+		return writeMP3(file, samples, sampleRate, channels, bitrate)
+	})
+}
+
+// Write interleaved samples as an MP3 stream
+func writeMP3(w io.Writer, samples []int16, sampleRate, channels, bitrate int) error {
+	enc := shine.NewEncoder(sampleRate, channels)
+	if err := setMP3Bitrate(enc, bitrate); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+
+	// Feed the encoder one frame at a time; a short final frame is padded
+	// with silence by the encoder. One extra silent frame flushes the
+	// filterbank so the end of the memo is not cut off.
+	frameLen := int(enc.Mpeg.GranulesPerFrame) * shine.GRANULE_SIZE * channels
+	for i := 0; i < len(samples)+frameLen; i += frameLen {
+		var chunk []int16
+		if i < len(samples) {
+			chunk = samples[i:min(i+frameLen, len(samples))]
+		}
+		data, written := enc.EncodeBufferInterleaved(chunk)
+		if _, err := out.Write(data[:written]); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+// Duplicate a mono signal into both channels of an interleaved stereo one
+func monoToStereo(samples []int16) []int16 {
+	stereo := make([]int16, len(samples)*2)
+	for i, sample := range samples {
+		stereo[i*2] = sample
+		stereo[i*2+1] = sample
+	}
+	return stereo
+}
+
+// The shine encoder always sets itself up for 128 kbps; recompute the
+// frame layout for the requested bitrate. This writes fields shine fills
+// in internally, which is why go.mod pins its version: check that
+// TestMP3Bitrate still passes before upgrading it.
+func setMP3Bitrate(enc *shine.Encoder, bitrate int) error {
+	table := mp3Bitrates(int(enc.Wave.SampleRate))
+
+	index := -1
+	for i, rate := range table {
+		if rate == bitrate && rate > 0 {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("unsupported MP3 bitrate: %d kbps", bitrate)
+	}
+
+	enc.Mpeg.Bitrate = int64(bitrate)
+	enc.Mpeg.BitrateIndex = int64(index)
+
+	slotsPerFrame := float64(enc.Mpeg.GranulesPerFrame) * shine.GRANULE_SIZE / float64(enc.Wave.SampleRate) *
+		float64(bitrate) * 1000 / float64(enc.Mpeg.BitsPerSlot)
+	enc.Mpeg.WholeSlotsPerFrame = int64(slotsPerFrame)
+	enc.Mpeg.FracSlotsPerFrame = slotsPerFrame - float64(enc.Mpeg.WholeSlotsPerFrame)
+	enc.Mpeg.SlotLag = -enc.Mpeg.FracSlotsPerFrame
+	if enc.Mpeg.FracSlotsPerFrame == 0 {
+		enc.Mpeg.Padding = 0
+	}
+	return nil
+}
+
+// Decode an MP3 file into interleaved 16-bit stereo samples
+func readMP3Data(filePath string) ([]int16, int, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer file.Close()
+
+	decoder, err := gomp3.NewDecoder(file)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("not a valid MP3 file: %w", err)
+	}
+
+	// The decoder always produces 16-bit little-endian stereo
+	data, err := io.ReadAll(decoder)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(uint16(data[i*2]) | uint16(data[i*2+1])<<8)
+	}
+
+	return samples, decoder.SampleRate(), 2, nil
+}
+
+// Decode an Ogg Vorbis file into interleaved 16-bit samples
+func readOggData(filePath string) ([]int16, int, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer file.Close()
+
+	data, format, err := oggvorbis.ReadAll(file)
+	if err == io.EOF {
+		// The decoder runs out of packets before producing anything when
+		// the stream holds no audio at all; read just the headers
+		if _, err = file.Seek(0, io.SeekStart); err == nil {
+			format, err = oggvorbis.GetFormat(file)
+		}
+	}
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("not a valid Ogg Vorbis file: %w", err)
+	}
+
+	samples := make([]int16, len(data))
+	for i, v := range data {
+		samples[i] = floatToInt16(float64(v))
+	}
+
+	return samples, format.SampleRate, format.Channels, nil
+}
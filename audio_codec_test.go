@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	gomp3 "github.com/hajimehoshi/go-mp3"
+)
+
+// Interleaved 16-bit frames of a sine wave on every channel
+func sineInt16(freq float64, sampleRate, channels int, seconds float64) []int16 {
+	frames := int(seconds * float64(sampleRate))
+	samples := make([]int16, frames*channels)
+	for i := 0; i < frames; i++ {
+		v := floatToInt16(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+		for ch := 0; ch < channels; ch++ {
+			samples[i*channels+ch] = v
+		}
+	}
+	return samples
+}
+
+func TestNearestMP3Bitrate(t *testing.T) {
+	tests := []struct {
+		sampleRate, bitrate, want int
+	}{
+		{44100, 128, 128},
+		{44100, 100, 96},   // Between 96 and 112, closer to 96
+		{44100, 104, 96},   // Tie goes to the lower rate
+		{48000, 1000, 320}, // Above the table
+		{44100, 8, 32},     // Below the MPEG-1 table
+		{22050, 320, 160},  // MPEG-2 tops out at 160
+		{22050, 8, 8},
+		{16000, 150, 144},
+		{8000, 128, 64}, // MPEG-2.5 tops out at 64
+		{11025, 20, 16},
+	}
+
+	for _, tt := range tests {
+		if got := nearestMP3Bitrate(tt.sampleRate, tt.bitrate); got != tt.want {
+			t.Errorf("nearestMP3Bitrate(%d, %d) = %d, want %d", tt.sampleRate, tt.bitrate, got, tt.want)
+		}
+	}
+}
+
+// setMP3Bitrate rewrites shine's internal frame layout, so check that the
+// stream really has the requested bitrate and still decodes
+func TestMP3Bitrate(t *testing.T) {
+	tests := []struct {
+		sampleRate, channels, bitrate, index int
+	}{
+		{44100, 2, 64, 5},
+		{44100, 2, 192, 11},
+		{48000, 2, 320, 14},
+		{22050, 1, 32, 4},
+	}
+
+	for _, tt := range tests {
+		samples := sineInt16(440, tt.sampleRate, tt.channels, 2)
+		var buf bytes.Buffer
+		if err := writeMP3(&buf, samples, tt.sampleRate, tt.channels, tt.bitrate); err != nil {
+			t.Fatalf("writeMP3 at %d kbps: %v", tt.bitrate, err)
+		}
+		data := buf.Bytes()
+
+		if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+			t.Fatalf("%d kbps: stream does not start with a frame header", tt.bitrate)
+		}
+		if index := int(data[2] >> 4); index != tt.index {
+			t.Errorf("%d kbps: frame header bitrate index = %d, want %d", tt.bitrate, index, tt.index)
+		}
+
+		// Two seconds plus the flushing frame
+		want := float64(tt.bitrate) * 1000 / 8 * 2
+		if size := float64(len(data)); size < want*0.95 || size > want*1.15 {
+			t.Errorf("%d kbps: %d bytes for 2s, want about %.0f", tt.bitrate, len(data), want)
+		}
+
+		decoder, err := gomp3.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%d kbps: decoding: %v", tt.bitrate, err)
+		}
+		pcm, err := io.ReadAll(decoder)
+		if err != nil {
+			t.Fatalf("%d kbps: decoding: %v", tt.bitrate, err)
+		}
+		// The decoder always produces 16-bit stereo
+		if frames := len(pcm) / 4; frames < 2*tt.sampleRate {
+			t.Errorf("%d kbps: decoded %d frames, want at least %d", tt.bitrate, frames, 2*tt.sampleRate)
+		}
+	}
+}
+
+// The settings step through the rates the encoder of the format accepts
+func TestAdjustBitrate(t *testing.T) {
+	tests := []struct {
+		format     AudioFormat
+		sampleRate int
+		mp3, ogg   int
+		delta      int
+		wantMP3    int
+		wantOgg    int
+	}{
+		{FormatMP3, 44100, 128, 64, 1, 160, 64},
+		{FormatMP3, 44100, 128, 64, -1, 112, 64},
+		{FormatMP3, 44100, 320, 64, 1, 32, 64},  // Wraps around
+		{FormatMP3, 22050, 192, 64, 1, 8, 64},   // From the top of the MPEG-2 table
+		{FormatMP3, 44100, 100, 64, 1, 112, 64}, // From the nearest valid rate
+		{FormatOGG, 44100, 128, 64, 1, 128, 80},
+		{FormatOGG, 44100, 128, 16, -1, 128, 256}, // Wraps around
+		{FormatOGG, 44100, 128, 70, 1, 128, 80},
+		{FormatWAV, 44100, 128, 64, 1, 128, 64},
+	}
+
+	for _, tt := range tests {
+		config := Config{DefaultFormat: tt.format, SampleRate: tt.sampleRate, MP3Bitrate: tt.mp3, OggBitrate: tt.ogg}
+		config.adjustBitrate(tt.delta)
+		if config.MP3Bitrate != tt.wantMP3 || config.OggBitrate != tt.wantOgg {
+			t.Errorf("%s at %d Hz, MP3 %d, Ogg %d, %+d: got MP3 %d, Ogg %d; want MP3 %d, Ogg %d",
+				tt.format, tt.sampleRate, tt.mp3, tt.ogg, tt.delta,
+				config.MP3Bitrate, config.OggBitrate, tt.wantMP3, tt.wantOgg)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Ogg page header flags
+const (
+	oggContinued = 0x01 // First packet on the page continues from the previous page
+	oggFirstPage = 0x02 // Beginning of stream
+	oggLastPage  = 0x04 // End of stream
+)
+
+// Pages are closed once their body grows past this size
+const oggPageTarget = 4096
+
+// CRC-32 with polynomial 0x04c11db7, as used in Ogg page checksums
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+func oggCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggWriter packs the packets of a single logical bitstream into Ogg pages
+type oggWriter struct {
+	w        io.Writer
+	serial   uint32
+	sequence uint32
+
+	// Pending page
+	segments  []byte // Lacing values
+	body      []byte
+	granule   int64 // Granule position after the last packet completed on the page
+	complete  bool  // Whether any packet completes on the page
+	continued bool  // Whether the page starts in the middle of a packet
+}
+
+func newOggWriter(w io.Writer, serial uint32) *oggWriter {
+	return &oggWriter{w: w, serial: serial}
+}
+
+// Append a packet ending at the given granule position. Packets are
+// collected into pages of about oggPageTarget bytes.
+func (o *oggWriter) writePacket(packet []byte, granule int64) error {
+	if len(o.body) >= oggPageTarget {
+		if err := o.writePage(0); err != nil {
+			return err
+		}
+	}
+
+	for midPacket := false; ; midPacket = true {
+		if len(o.segments) == 255 {
+			if err := o.writePage(0); err != nil {
+				return err
+			}
+			o.continued = midPacket
+		}
+
+		n := min(len(packet), 255)
+		o.segments = append(o.segments, byte(n))
+		o.body = append(o.body, packet[:n]...)
+		packet = packet[n:]
+
+		// A lacing value below 255 terminates the packet
+		if n < 255 {
+			break
+		}
+	}
+
+	o.granule = granule
+	o.complete = true
+	return nil
+}
+
+// End the pending page so the next packet starts on a fresh one, as the
+// Vorbis spec requires after the identification and setup headers
+func (o *oggWriter) flush() error {
+	if len(o.segments) == 0 {
+		return nil
+	}
+	return o.writePage(0)
+}
+
+// Write the pending page as the last page of the stream
+func (o *oggWriter) close() error {
+	return o.writePage(oggLastPage)
+}
+
+func (o *oggWriter) writePage(flags byte) error {
+	if o.continued {
+		flags |= oggContinued
+	}
+	if o.sequence == 0 {
+		flags |= oggFirstPage
+	}
+
+	// Pages on which no packet ends carry a granule position of -1
+	granule := int64(-1)
+	if o.complete {
+		granule = o.granule
+	}
+
+	page := make([]byte, 27, 27+len(o.segments)+len(o.body))
+	copy(page, "OggS")
+	page[4] = 0 // Stream structure version
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.sequence)
+	page[26] = byte(len(o.segments))
+	page = append(page, o.segments...)
+	page = append(page, o.body...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(0, page))
+
+	if _, err := o.w.Write(page); err != nil {
+		return err
+	}
+
+	o.sequence++
+	o.segments = o.segments[:0]
+	o.body = o.body[:0]
+	o.complete = false
+	o.continued = false
+	return nil
+}
@@ -0,0 +1,249 @@
+//go:build !noportaudio
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// portAudioBackend records and plays through the system audio devices.
+// PortAudio is initialized for the lifetime of each open stream and
+// terminated again when the stream is closed.
+type portAudioBackend struct{}
+
+func newPortAudioBackend() AudioBackend {
+	return portAudioBackend{}
+}
+
+func (portAudioBackend) Name() string { return BackendPortAudio }
+
+// Enumerate devices from all host APIs
+func (portAudioBackend) Devices() ([]AudioDeviceInfo, error) {
+	var devices []AudioDeviceInfo
+
+	// Initialize PortAudio
+	if err := portaudio.Initialize(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := portaudio.Terminate(); err != nil {
+			log.Printf("Error terminating PortAudio: %v", err)
+		}
+	}()
+
+	// Get host APIs (e.g., ALSA on Linux, CoreAudio on macOS)
+	hostApis, err := portaudio.HostApis()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get default devices
+	defaultInput, _ := portaudio.DefaultInputDevice()
+	defaultOutput, _ := portaudio.DefaultOutputDevice()
+
+	for _, host := range hostApis {
+		log.Printf("Host API: %s", host.Name)
+		for _, dev := range host.Devices {
+			// Skip devices with no I/O channels
+			if dev.MaxInputChannels == 0 && dev.MaxOutputChannels == 0 {
+				continue
+			}
+
+			// Create device info
+			info := AudioDeviceInfo{
+				ID:   fmt.Sprintf("%d", dev.Index), // Unique ID based on PortAudio index
+				Name: fmt.Sprintf("%s (%s)", dev.Name, host.Name),
+				IsDefault: (defaultInput != nil && dev.Index == defaultInput.Index) ||
+					(defaultOutput != nil && dev.Index == defaultOutput.Index),
+				IsInput:  dev.MaxInputChannels > 0,
+				IsOutput: dev.MaxOutputChannels > 0,
+			}
+			log.Printf("Found device: ID=%s, Name=%s, Input=%v, Output=%v, Channels=%d",
+				info.ID, info.Name, info.IsInput, info.IsOutput, dev.MaxInputChannels)
+			devices = append(devices, info)
+		}
+	}
+
+	return devices, nil
+}
+
+// Open an input stream, preferring the device's own sample rate and
+// channel count over the requested ones
+func (portAudioBackend) OpenInputStream(cfg StreamConfig, process func(in []float32)) (AudioStream, error) {
+	cfg = cfg.withDefaults()
+
+	// Initialize PortAudio
+	if err := portaudio.Initialize(); err != nil {
+		return nil, fmt.Errorf("initializing PortAudio: %w", err)
+	}
+
+	// Find selected input device
+	var inputDev *portaudio.DeviceInfo
+	if cfg.DeviceID != "" {
+		inputDev = getDeviceByID(cfg.DeviceID)
+		if inputDev != nil {
+			log.Printf("Found input device: %s (channels: %d)", inputDev.Name, inputDev.MaxInputChannels)
+		} else {
+			log.Printf("Could not find input device with ID: %s", cfg.DeviceID)
+		}
+	}
+
+	// Fallback to default input device
+	if inputDev == nil {
+		inputDev, _ = portaudio.DefaultInputDevice()
+		log.Printf("Using default input device")
+		if inputDev != nil {
+			log.Printf("Default input device: %s (channels: %d)", inputDev.Name, inputDev.MaxInputChannels)
+		}
+	}
+
+	if inputDev == nil {
+		terminatePortAudio()
+		return nil, fmt.Errorf("no input device available")
+	}
+
+	// Set up audio parameters - try to use device's preferred format
+	params := portaudio.HighLatencyParameters(inputDev, nil)
+
+	// Try to use device's preferred sample rate, fallback to config
+	if inputDev.DefaultSampleRate > 0 {
+		params.SampleRate = inputDev.DefaultSampleRate
+		log.Printf("Using device's preferred sample rate: %.0f Hz", params.SampleRate)
+	} else {
+		params.SampleRate = float64(cfg.SampleRate)
+		log.Printf("Using config sample rate: %.0f Hz", params.SampleRate)
+	}
+
+	// Try to use device's preferred channel count, fallback to config
+	if inputDev.MaxInputChannels > 0 {
+		// Use minimum of device max and our config
+		channels := cfg.Channels
+		if inputDev.MaxInputChannels < channels {
+			channels = inputDev.MaxInputChannels
+		}
+		params.Input.Channels = channels
+		log.Printf("Using %d input channels (device max: %d, config: %d)",
+			channels, inputDev.MaxInputChannels, cfg.Channels)
+	} else {
+		params.Input.Channels = cfg.Channels
+		log.Printf("Using config channel count: %d", params.Input.Channels)
+	}
+
+	params.FramesPerBuffer = cfg.FramesPerBuffer
+
+	stream, err := portaudio.OpenStream(params, process)
+	if err != nil {
+		terminatePortAudio()
+		return nil, err
+	}
+
+	log.Printf("Opened input stream on device: %s", inputDev.Name)
+	return &portAudioStream{
+		stream:     stream,
+		sampleRate: int(params.SampleRate),
+		channels:   params.Input.Channels,
+	}, nil
+}
+
+// Open an output stream in the requested format, or at the device rate if
+// the requested rate is not supported
+func (portAudioBackend) OpenOutputStream(cfg StreamConfig, process func(out []int16)) (AudioStream, error) {
+	cfg = cfg.withDefaults()
+
+	// Initialize PortAudio
+	if err := portaudio.Initialize(); err != nil {
+		return nil, fmt.Errorf("initializing PortAudio: %w", err)
+	}
+
+	// Find selected output device
+	var outputDev *portaudio.DeviceInfo
+	if cfg.DeviceID != "" {
+		outputDev = getDeviceByID(cfg.DeviceID)
+	}
+
+	// Fallback to default output device
+	if outputDev == nil {
+		outputDev, _ = portaudio.DefaultOutputDevice()
+	}
+
+	if outputDev == nil {
+		terminatePortAudio()
+		return nil, fmt.Errorf("no output device available")
+	}
+
+	// Set up audio parameters
+	params := portaudio.HighLatencyParameters(nil, outputDev)
+	params.SampleRate = float64(cfg.SampleRate)
+	params.Output.Channels = cfg.Channels
+	params.FramesPerBuffer = cfg.FramesPerBuffer
+
+	// Fall back to the device's own rate when it can't play the requested
+	// one; callers resample to whatever rate the stream reports
+	if err := portaudio.IsFormatSupported(params, process); err != nil && outputDev.DefaultSampleRate > 0 {
+		log.Printf("Output device does not support %d Hz (%v), using %.0f Hz",
+			cfg.SampleRate, err, outputDev.DefaultSampleRate)
+		params.SampleRate = outputDev.DefaultSampleRate
+	}
+
+	stream, err := portaudio.OpenStream(params, process)
+	if err != nil {
+		terminatePortAudio()
+		return nil, err
+	}
+
+	log.Printf("Opened output stream on device: %s", outputDev.Name)
+	return &portAudioStream{
+		stream:     stream,
+		sampleRate: int(params.SampleRate),
+		channels:   cfg.Channels,
+	}, nil
+}
+
+// portAudioStream wraps a PortAudio stream and releases PortAudio on Close
+type portAudioStream struct {
+	stream     *portaudio.Stream
+	sampleRate int
+	channels   int
+}
+
+func (s *portAudioStream) Start() error { return s.stream.Start() }
+func (s *portAudioStream) Stop() error  { return s.stream.Stop() }
+
+func (s *portAudioStream) Close() error {
+	err := s.stream.Close()
+	terminatePortAudio()
+	return err
+}
+
+func (s *portAudioStream) SampleRate() int { return s.sampleRate }
+func (s *portAudioStream) Channels() int   { return s.channels }
+
+// Get device by ID from PortAudio
+func getDeviceByID(deviceID string) *portaudio.DeviceInfo {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil
+	}
+
+	deviceIdx, err := strconv.Atoi(deviceID)
+	if err != nil {
+		return nil
+	}
+
+	if deviceIdx >= 0 && deviceIdx < len(devices) {
+		return devices[deviceIdx]
+	}
+
+	return nil
+}
+
+// Terminate PortAudio, logging any error
+func terminatePortAudio() {
+	if err := portaudio.Terminate(); err != nil {
+		log.Printf("Error terminating PortAudio: %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build noportaudio
+
+package main
+
+import "log"
+
+// Builds tagged noportaudio do not link against PortAudio, so machines
+// without the PortAudio libraries fall back to the null backend
+func newPortAudioBackend() AudioBackend {
+	log.Printf("Built without PortAudio support, using %s audio backend", BackendNull)
+	return newNullBackend()
+}
@@ -0,0 +1,838 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// Vorbis I encoder for Ogg memos.
+//
+// The setup is deliberately small and fixed: only long blocks are used, the
+// spectral envelope is coded as a floor 1 curve, and the residue is coded
+// with lattice codebooks. The floor doubles as the quantizer step size, so
+// the bitrate is controlled by how far below the band levels the floor
+// is placed. That is plenty for speech, which is what memos contain.
+//
+// Vorbis rather than Opus because both have to be written in Go to keep
+// the build free of C encoders: Opus would need libopus through cgo, and
+// there is no pure Go encoder for either. Vorbis is the simpler of the
+// two to encode, and the decoder used for playback is pure Go as well.
+
+// Ogg Vorbis bitrate settings
+const (
+	DefaultOggBitrate = 64 // kbps
+	MinOggBitrate     = 16
+	MaxOggBitrate     = 256
+)
+
+// Ogg Vorbis bitrates offered in the settings, in kbps
+var oggBitrates = []int{16, 24, 32, 48, 64, 80, 96, 128, 160, 192, 256}
+
+const (
+	vorbisShortExp = 8  // Short blocks of 256 samples are declared but never used
+	vorbisLongExp  = 11 // Long blocks of 2048 samples
+	vorbisBlock    = 1 << vorbisLongExp
+	vorbisSpectrum = vorbisBlock / 2 // MDCT coefficients per block
+
+	vorbisFloorMultiplier = 2
+	vorbisFloorRange      = 128 // Floor 1 Y range for multiplier 2
+	vorbisFloorRangeBits  = 10  // Post positions span the 1024 coefficients
+	vorbisFloorPartitions = 8
+	vorbisFloorClassDim   = 4
+
+	vorbisPartitionSize = 16 // Residue coefficients per partition
+	vorbisClassWords    = 2  // Partition classes coded per classbook entry
+
+	// Quantization steps relative to the band levels, in dB below them
+	vorbisMinSNR = -12.0
+	vorbisMaxSNR = 30.0
+
+	// How closely the quantization step follows each band's level (1) rather
+	// than the loudest band's (0). Quiet bands next to loud ones are masked,
+	// so they get coarser steps than their own level calls for.
+	vorbisMaskingTilt = 0.3
+
+	// Values are rounded towards zero by this much, which saves more bits on
+	// the many near-zero coefficients than it costs in accuracy
+	vorbisDeadZone = 0.2
+
+	// Absolute floor under the quantization step, roughly -80 dBFS
+	vorbisNoiseFloor = 1e-4
+
+	vorbisVendor = "voicelog"
+)
+
+// Codebook numbers in the setup header
+const (
+	bookFloorY = iota
+	bookResidueClass
+	bookResidueUnit   // Values -1..1, 4 per entry
+	bookResidueSmall  // Values -2..2, 2 per entry
+	bookResidueMedium // Values -7..7, 2 per entry
+	bookResidueCoarse // Multiples of 15 in -120..120, 2 per entry
+)
+
+// Residue partition classes, picked by a partition's largest quantized value.
+// The largest class codes each value as a coarse multiple of 15 followed by
+// a medium-book correction in a second pass.
+var residueClassLimits = []int{0, 1, 2, 7, 127}
+
+const residueCoarseStep = 15
+
+// Codebooks used by each residue class in passes 0 and 1, -1 for none
+var residueClassBooks = [][2]int{
+	{-1, -1},
+	{bookResidueUnit, -1},
+	{bookResidueSmall, -1},
+	{bookResidueMedium, -1},
+	{bookResidueCoarse, bookResidueMedium},
+}
+
+// Floor 1 amplitude table from the Vorbis I spec: 256 steps spaced
+// about 0.55 dB apart, ending at 1.0
+var vorbisInverseDB = func() [256]float64 {
+	var table [256]float64
+	for i := range table {
+		table[i] = math.Pow(1.0649863, float64(i-255))
+	}
+	return table
+}()
+
+// Encode a WAV file to Ogg Vorbis at the given average bitrate (kbps)
+func encodeOggFile(srcPath, dstPath string, bitrate int) error {
+	samples, sampleRate, channels, err := readWAVData(srcPath)
+	if err != nil {
+		return err
+	}
+	if channels < 1 || channels > 255 {
+		return fmt.Errorf("Ogg Vorbis supports 1 to 255 channels, got %d", channels)
+	}
+	if bitrate < MinOggBitrate || bitrate > MaxOggBitrate {
+		return fmt.Errorf("unsupported Ogg Vorbis bitrate: %d kbps", bitrate)
+	}
+
+	return writeFileAtomic(dstPath, func(file *os.File) error {
+		return writeOggVorbis(file, samples, sampleRate, channels, bitrate)
+	})
+}
+
+// Write interleaved samples as an Ogg Vorbis stream
+func writeOggVorbis(w io.Writer, samples []int16, sampleRate, channels, bitrate int) error {
+	out := bufio.NewWriter(w)
+	ogg := newOggWriter(out, rand.Uint32())
+	enc := newVorbisEncoder(sampleRate, channels, bitrate)
+
+	// The identification header sits alone on the first page, and audio
+	// starts on a fresh page after the comment and setup headers
+	if err := ogg.writePacket(enc.identificationHeader(), 0); err != nil {
+		return err
+	}
+	if err := ogg.flush(); err != nil {
+		return err
+	}
+	if err := ogg.writePacket(enc.commentHeader(), 0); err != nil {
+		return err
+	}
+	if err := ogg.writePacket(enc.setupHeader(), 0); err != nil {
+		return err
+	}
+	if err := ogg.flush(); err != nil {
+		return err
+	}
+
+	// Block k covers frames (k-1)*1024 to (k+1)*1024. The first packet only
+	// primes the decoder's overlap, and every later one completes another
+	// 1024 frames; the final granule position trims the padding. Decoders
+	// need at least two packets before they produce anything.
+	frames := len(samples) / channels
+	blocks := max((frames+vorbisSpectrum-1)/vorbisSpectrum+1, 2)
+	for k := 0; k < blocks; k++ {
+		packet := enc.encodeBlock(samples, (k-1)*vorbisSpectrum)
+		granule := int64(min(k*vorbisSpectrum, frames))
+		if err := ogg.writePacket(packet, granule); err != nil {
+			return err
+		}
+	}
+
+	if err := ogg.close(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// vorbisEncoder holds the fixed stream setup and the bit budget state
+type vorbisEncoder struct {
+	sampleRate int
+	channels   int
+	bitrate    int // bits per second
+
+	books  []*vorbisCodebook
+	floorX []int // Floor post positions in header order
+	sorted []int // Post indices ordered by position
+	bands  [][2]int
+
+	window []float64
+	fftRe  []float64
+	fftIm  []float64
+
+	blockBits float64 // Average bits available per packet
+	reservoir float64 // Bits saved (positive) or overspent by earlier packets
+}
+
+// Per-channel analysis of one block
+type vorbisChannel struct {
+	coeffs []float64
+	levels []float64 // RMS level around each floor post
+	peak   float64   // Highest of the levels
+
+	used   bool
+	floorY []int // Coded floor values in header order
+	curve  []float64
+	q      []int // Quantized residue
+}
+
+func newVorbisEncoder(sampleRate, channels, bitrate int) *vorbisEncoder {
+	e := &vorbisEncoder{
+		sampleRate: sampleRate,
+		channels:   channels,
+		bitrate:    bitrate * 1000,
+		books:      vorbisCodebooks(),
+		window:     make([]float64, vorbisBlock),
+		fftRe:      make([]float64, vorbisSpectrum/2),
+		fftIm:      make([]float64, vorbisSpectrum/2),
+	}
+	e.blockBits = float64(e.bitrate) * vorbisSpectrum / float64(sampleRate)
+
+	// Vorbis power-complementary window
+	for i := range e.window {
+		x := math.Sin((float64(i) + 0.5) / vorbisSpectrum * math.Pi / 2)
+		e.window[i] = math.Sin(math.Pi / 2 * x * x)
+	}
+
+	e.floorX, e.sorted = vorbisFloorPosts()
+
+	// Each post measures the band reaching halfway to its neighbours
+	e.bands = make([][2]int, len(e.floorX))
+	for j, i := range e.sorted {
+		lo, hi := 0, vorbisSpectrum
+		if j > 0 {
+			lo = (e.floorX[e.sorted[j-1]] + e.floorX[i]) / 2
+		}
+		if j < len(e.sorted)-1 {
+			hi = (e.floorX[i] + e.floorX[e.sorted[j+1]]) / 2
+		}
+		e.bands[i] = [2]int{lo, max(hi, lo+1)}
+	}
+
+	return e
+}
+
+// Floor post positions, roughly logarithmic in frequency. Posts are listed
+// coarse to fine so each one is predicted from already coded neighbours.
+func vorbisFloorPosts() (header []int, sorted []int) {
+	count := vorbisFloorPartitions * vorbisFloorClassDim
+	positions := make([]int, 0, count)
+	last := 0
+	for k := 0; k < count; k++ {
+		x := int(math.Round(2 * math.Pow(float64(vorbisSpectrum-1)/2, float64(k)/float64(count-1))))
+		x = max(x, last+2)
+		positions = append(positions, x)
+		last = x
+	}
+
+	header = []int{0, vorbisSpectrum}
+	ranges := [][2]int{{0, len(positions)}}
+	for len(ranges) > 0 {
+		r := ranges[0]
+		ranges = ranges[1:]
+		if r[0] >= r[1] {
+			continue
+		}
+		mid := (r[0] + r[1]) / 2
+		header = append(header, positions[mid])
+		ranges = append(ranges, [2]int{r[0], mid}, [2]int{mid + 1, r[1]})
+	}
+
+	sorted = make([]int, len(header))
+	for i := range positions {
+		for h, x := range header {
+			if x == positions[i] {
+				sorted[i+1] = h
+			}
+		}
+	}
+	sorted[len(sorted)-1] = 1
+	return header, sorted
+}
+
+// The codebooks of the setup header, with code lengths built from rough
+// symbol statistics of speech
+func vorbisCodebooks() []*vorbisCodebook {
+	books := make([]*vorbisCodebook, bookResidueCoarse+1)
+
+	floorWeights := make([]float64, vorbisFloorRange)
+	for i := range floorWeights {
+		floorWeights[i] = math.Exp(-0.25 * float64(i))
+	}
+	books[bookFloorY] = newScalarCodebook(1, floorWeights)
+
+	classProbs := []float64{0.35, 0.25, 0.18, 0.14, 0.08}
+	classWeights := make([]float64, len(classProbs)*len(classProbs))
+	for i := range classWeights {
+		classWeights[i] = classProbs[i/len(classProbs)] * classProbs[i%len(classProbs)]
+	}
+	books[bookResidueClass] = newScalarCodebook(vorbisClassWords, classWeights)
+
+	books[bookResidueUnit] = newLatticeCodebook(4, 3, -1, 1, func(v int) float64 {
+		if v == 0 {
+			return 0.6
+		}
+		return 0.2
+	})
+	books[bookResidueSmall] = newLatticeCodebook(2, 5, -2, 1, func(v int) float64 {
+		return math.Exp(-0.9 * math.Abs(float64(v)))
+	})
+	books[bookResidueMedium] = newLatticeCodebook(2, 15, -7, 1, func(v int) float64 {
+		return math.Exp(-0.45 * math.Abs(float64(v)))
+	})
+	books[bookResidueCoarse] = newLatticeCodebook(2, 17, -8*residueCoarseStep, residueCoarseStep, func(v int) float64 {
+		return math.Exp(-0.6 * math.Abs(float64(v)) / residueCoarseStep)
+	})
+	return books
+}
+
+func (e *vorbisEncoder) identificationHeader() []byte {
+	w := &vorbisBitWriter{}
+	w.writeHeaderType(1)
+	w.write(0, 32) // Vorbis version
+	w.write(uint32(e.channels), 8)
+	w.write(uint32(e.sampleRate), 32)
+	w.write(0, 32) // Maximum bitrate
+	w.write(uint32(e.bitrate), 32)
+	w.write(0, 32) // Minimum bitrate
+	w.write(vorbisShortExp, 4)
+	w.write(vorbisLongExp, 4)
+	w.write(1, 1) // Framing
+	return w.bytes()
+}
+
+func (e *vorbisEncoder) commentHeader() []byte {
+	w := &vorbisBitWriter{}
+	w.writeHeaderType(3)
+	w.write(uint32(len(vorbisVendor)), 32)
+	for i := 0; i < len(vorbisVendor); i++ {
+		w.write(uint32(vorbisVendor[i]), 8)
+	}
+	w.write(0, 32) // No user comments
+	w.write(1, 1)  // Framing
+	return w.bytes()
+}
+
+func (e *vorbisEncoder) setupHeader() []byte {
+	w := &vorbisBitWriter{}
+	w.writeHeaderType(5)
+
+	w.write(uint32(len(e.books)-1), 8)
+	for _, book := range e.books {
+		book.writeHeader(w)
+	}
+
+	// One placeholder time domain transform
+	w.write(0, 6)
+	w.write(0, 16)
+
+	// One floor 1 with a single partition class
+	w.write(0, 6)
+	w.write(1, 16)
+	w.write(vorbisFloorPartitions, 5)
+	for i := 0; i < vorbisFloorPartitions; i++ {
+		w.write(0, 4)
+	}
+	w.write(vorbisFloorClassDim-1, 3)
+	w.write(0, 2) // No subclasses
+	w.write(bookFloorY+1, 8)
+	w.write(vorbisFloorMultiplier-1, 2)
+	w.write(vorbisFloorRangeBits, 4)
+	for _, x := range e.floorX[2:] {
+		w.write(uint32(x), vorbisFloorRangeBits)
+	}
+
+	// One residue of type 1 over the whole spectrum
+	w.write(0, 6)
+	w.write(1, 16)
+	w.write(0, 24)
+	w.write(vorbisSpectrum, 24)
+	w.write(vorbisPartitionSize-1, 24)
+	w.write(uint32(len(residueClassBooks)-1), 6)
+	w.write(bookResidueClass, 8)
+	for _, books := range residueClassBooks {
+		cascade := uint32(0)
+		for pass, book := range books {
+			if book >= 0 {
+				cascade |= 1 << pass
+			}
+		}
+		w.write(cascade, 3)
+		w.write(0, 1) // No high cascade bits
+	}
+	for _, books := range residueClassBooks {
+		for _, book := range books {
+			if book >= 0 {
+				w.write(uint32(book), 8)
+			}
+		}
+	}
+
+	// One mapping: every channel uses the floor and residue, no coupling
+	w.write(0, 6)
+	w.write(0, 16)
+	w.write(0, 1) // Single submap
+	w.write(0, 1) // No coupling
+	w.write(0, 2) // Reserved
+	w.write(0, 8) // Time configuration
+	w.write(0, 8) // Floor
+	w.write(0, 8) // Residue
+
+	// One long-block mode
+	w.write(0, 6)
+	w.write(1, 1)
+	w.write(0, 16) // Window type
+	w.write(0, 16) // Transform type
+	w.write(0, 8)  // Mapping
+
+	w.write(1, 1) // Framing
+	return w.bytes()
+}
+
+// Encode the block of frames starting at start (which may be negative at
+// the beginning of the stream) into an audio packet
+func (e *vorbisEncoder) encodeBlock(samples []int16, start int) []byte {
+	frames := len(samples) / e.channels
+	chans := make([]*vorbisChannel, e.channels)
+	block := make([]float64, vorbisBlock)
+
+	for ch := range chans {
+		for i := range block {
+			pos := start + i
+			block[i] = 0
+			if pos >= 0 && pos < frames {
+				block[i] = float64(samples[pos*e.channels+ch]) / 32768 * e.window[i]
+			}
+		}
+
+		c := &vorbisChannel{
+			coeffs: make([]float64, vorbisSpectrum),
+			levels: make([]float64, len(e.floorX)),
+			curve:  make([]float64, vorbisSpectrum),
+			q:      make([]int, vorbisSpectrum),
+		}
+		e.mdct(block, c.coeffs)
+		for i, band := range e.bands {
+			var sum float64
+			for _, v := range c.coeffs[band[0]:band[1]] {
+				sum += v * v
+			}
+			c.levels[i] = math.Sqrt(sum / float64(band[1]-band[0]))
+			c.peak = math.Max(c.peak, c.levels[i])
+		}
+		chans[ch] = c
+	}
+
+	// Spend the average share of the bitrate plus part of whatever earlier
+	// packets left over, at the finest quantization that fits
+	budget := e.blockBits + e.reservoir/4
+	budget = math.Max(budget, e.blockBits/4)
+
+	w := &vorbisBitWriter{}
+	e.writePacket(w, chans, vorbisMaxSNR)
+	if float64(w.bitLen()) > budget {
+		lo, hi := vorbisMinSNR, vorbisMaxSNR
+		for i := 0; i < 6; i++ {
+			mid := (lo + hi) / 2
+			w.reset()
+			e.writePacket(w, chans, mid)
+			if float64(w.bitLen()) > budget {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		w.reset()
+		e.writePacket(w, chans, lo)
+	}
+
+	e.reservoir += e.blockBits - float64(w.bitLen())
+	e.reservoir = math.Min(e.reservoir, 8*e.blockBits)
+	return w.bytes()
+}
+
+// Quantize every channel at the given step size and write the packet
+func (e *vorbisEncoder) writePacket(w *vorbisBitWriter, chans []*vorbisChannel, snr float64) {
+	ratio := math.Pow(10, -snr/20)
+	for _, c := range chans {
+		e.quantize(c, ratio)
+	}
+
+	w.write(0, 1) // Audio packet
+	w.write(1, 1) // Previous window is long
+	w.write(1, 1) // Next window is long
+
+	yBits := ilog(vorbisFloorRange - 1)
+	for _, c := range chans {
+		if !c.used {
+			w.write(0, 1)
+			continue
+		}
+		w.write(1, 1)
+		w.write(uint32(c.floorY[0]), yBits)
+		w.write(uint32(c.floorY[1]), yBits)
+		for _, y := range c.floorY[2:] {
+			e.books[bookFloorY].writeEntry(w, y)
+		}
+	}
+
+	e.writeResidue(w, chans)
+}
+
+// Place the floor ratio below the band levels, code it, and quantize the
+// coefficients against the curve the decoder will reconstruct
+func (e *vorbisEncoder) quantize(c *vorbisChannel, ratio float64) {
+	target := make([]int, len(e.floorX))
+	for i, level := range c.levels {
+		step := ratio * math.Pow(level, vorbisMaskingTilt) * math.Pow(c.peak, 1-vorbisMaskingTilt)
+		target[i] = floorTarget(math.Max(step, vorbisNoiseFloor))
+	}
+
+	// Peaks between posts can sit where the interpolated curve dips; raise
+	// the posts around any value the residue books cannot reach and retry
+	limit := float64(residueClassLimits[len(residueClassLimits)-1])
+	for attempt := 0; ; attempt++ {
+		finalY, step2 := e.codeFloor(target, c)
+		e.renderFloor(finalY, step2, c.curve)
+		if attempt == 3 {
+			break
+		}
+
+		raised := false
+		for j := 1; j < len(e.sorted); j++ {
+			lo, hi := e.sorted[j-1], e.sorted[j]
+			for x := e.floorX[lo]; x < min(e.floorX[hi], vorbisSpectrum); x++ {
+				if math.Abs(c.coeffs[x]) > limit*c.curve[x] {
+					y := floorTarget(math.Abs(c.coeffs[x]) / limit)
+					target[lo] = max(target[lo], y)
+					target[hi] = max(target[hi], y)
+					raised = true
+				}
+			}
+		}
+		if !raised {
+			break
+		}
+	}
+
+	c.used = false
+	for i, v := range c.coeffs {
+		q := int(math.Copysign(math.Floor(math.Abs(v/c.curve[i])+0.5-vorbisDeadZone), v))
+		q = min(max(q, -int(limit)), int(limit))
+		c.q[i] = q
+		if q != 0 {
+			c.used = true
+		}
+	}
+}
+
+// The floor value whose amplitude is closest to step, rounded up so the
+// step is never smaller than asked
+func floorTarget(step float64) int {
+	index := 255 + math.Log(step)/math.Log(1.0649863)
+	return min(max(int(math.Ceil(index/vorbisFloorMultiplier)), 0), vorbisFloorRange-1)
+}
+
+// Code the target floor values, mirroring the decoder's prediction so the
+// encoder knows the exact curve it will render
+func (e *vorbisEncoder) codeFloor(target []int, c *vorbisChannel) (finalY []int, step2 []bool) {
+	n := len(e.floorX)
+	if c.floorY == nil {
+		c.floorY = make([]int, n)
+	}
+	finalY = make([]int, n)
+	step2 = make([]bool, n)
+
+	finalY[0], finalY[1] = target[0], target[1]
+	c.floorY[0], c.floorY[1] = target[0], target[1]
+	step2[0], step2[1] = true, true
+
+	for i := 2; i < n; i++ {
+		low, high := floorNeighbors(e.floorX, i)
+		predicted := floorRenderPoint(e.floorX[low], finalY[low], e.floorX[high], finalY[high], e.floorX[i])
+
+		// Differences of one step are not worth the bits
+		diff := target[i] - predicted
+		if diff >= -1 && diff <= 1 {
+			c.floorY[i] = 0
+			finalY[i] = predicted
+			continue
+		}
+
+		highRoom := vorbisFloorRange - predicted
+		lowRoom := predicted
+		var val int
+		switch {
+		case highRoom > lowRoom && diff >= lowRoom:
+			val = diff + lowRoom
+		case highRoom <= lowRoom && diff <= -(highRoom+1):
+			val = highRoom - 1 - diff
+		case diff > 0:
+			val = 2 * diff
+		default:
+			val = -2*diff - 1
+		}
+
+		c.floorY[i] = val
+		finalY[i] = target[i]
+		step2[low], step2[high], step2[i] = true, true, true
+	}
+	return finalY, step2
+}
+
+// Render the floor curve exactly as the decoder does
+func (e *vorbisEncoder) renderFloor(finalY []int, step2 []bool, curve []float64) {
+	lx, ly := 0, finalY[0]*vorbisFloorMultiplier
+	hx, hy := 0, 0
+	for _, i := range e.sorted[1:] {
+		if step2[i] {
+			hx, hy = e.floorX[i], finalY[i]*vorbisFloorMultiplier
+			floorRenderLine(lx, ly, hx, hy, curve)
+			lx, ly = hx, hy
+		}
+	}
+	for x := hx; x < len(curve); x++ {
+		curve[x] = vorbisInverseDB[hy]
+	}
+}
+
+func floorNeighbors(xs []int, i int) (low, high int) {
+	lowX, highX := -1, math.MaxInt
+	for j := 0; j < i; j++ {
+		if xs[j] < xs[i] && xs[j] > lowX {
+			low, lowX = j, xs[j]
+		}
+		if xs[j] > xs[i] && xs[j] < highX {
+			high, highX = j, xs[j]
+		}
+	}
+	return low, high
+}
+
+func floorRenderPoint(x0, y0, x1, y1, x int) int {
+	dy := y1 - y0
+	ady := dy
+	if ady < 0 {
+		ady = -ady
+	}
+	off := ady * (x - x0) / (x1 - x0)
+	if dy < 0 {
+		return y0 - off
+	}
+	return y0 + off
+}
+
+func floorRenderLine(x0, y0, x1, y1 int, curve []float64) {
+	dy := y1 - y0
+	adx := x1 - x0
+	base := dy / adx
+	ady := dy
+	if ady < 0 {
+		ady = -ady
+	}
+	absBase := base
+	if absBase < 0 {
+		absBase = -absBase
+	}
+	ady -= absBase * adx
+
+	sy := base + 1
+	if dy < 0 {
+		sy = base - 1
+	}
+
+	y, err := y0, 0
+	if x0 < len(curve) {
+		curve[x0] = vorbisInverseDB[y]
+	}
+	for x := x0 + 1; x < x1 && x < len(curve); x++ {
+		err += ady
+		if err >= adx {
+			err -= adx
+			y += sy
+		} else {
+			y += base
+		}
+		curve[x] = vorbisInverseDB[y]
+	}
+}
+
+// Write the residue vectors of all used channels in the interleaved order
+// of residue type 1
+func (e *vorbisEncoder) writeResidue(w *vorbisBitWriter, chans []*vorbisChannel) {
+	partitions := vorbisSpectrum / vorbisPartitionSize
+	classes := make([][]int, len(chans))
+	for ch, c := range chans {
+		if !c.used {
+			continue
+		}
+		classes[ch] = make([]int, partitions)
+		for p := range classes[ch] {
+			peak := 0
+			for _, q := range c.q[p*vorbisPartitionSize : (p+1)*vorbisPartitionSize] {
+				peak = max(peak, q, -q)
+			}
+			for class, limit := range residueClassLimits {
+				if peak <= limit {
+					classes[ch][p] = class
+					break
+				}
+			}
+		}
+	}
+
+	classCount := len(residueClassBooks)
+	values := make([]int, vorbisPartitionSize)
+	for pass := 0; pass < 2; pass++ {
+		for p := 0; p < partitions; {
+			if pass == 0 {
+				for ch, c := range chans {
+					if !c.used {
+						continue
+					}
+					entry := 0
+					for i := 0; i < vorbisClassWords; i++ {
+						entry = entry*classCount + classes[ch][p+i]
+					}
+					e.books[bookResidueClass].writeEntry(w, entry)
+				}
+			}
+
+			for i := 0; i < vorbisClassWords && p < partitions; i++ {
+				for ch, c := range chans {
+					if !c.used {
+						continue
+					}
+					class := classes[ch][p]
+					book := residueClassBooks[class][pass]
+					if book < 0 {
+						continue
+					}
+
+					part := c.q[p*vorbisPartitionSize : (p+1)*vorbisPartitionSize]
+					for k, q := range part {
+						values[k] = residuePassValue(q, class, pass)
+					}
+					e.books[book].writeVectors(w, values)
+				}
+				p++
+			}
+		}
+	}
+}
+
+// The part of a quantized value coded in the given pass
+func residuePassValue(q, class, pass int) int {
+	if residueClassBooks[class][1] < 0 {
+		return q
+	}
+	coarse := int(math.Round(float64(q)/residueCoarseStep)) * residueCoarseStep
+	if pass == 0 {
+		return coarse
+	}
+	return q - coarse
+}
+
+// Forward MDCT of a windowed block into vorbisSpectrum coefficients, scaled
+// for the Vorbis inverse transform. The block is folded into a DCT-IV,
+// which is computed with a quarter-length complex FFT.
+func (e *vorbisEncoder) mdct(block, out []float64) {
+	n := len(out)
+	u := make([]float64, n)
+	for i := 0; i < n/2; i++ {
+		u[i] = -block[3*n/2-1-i] - block[3*n/2+i]
+	}
+	for i := n / 2; i < n; i++ {
+		u[i] = block[i-n/2] - block[3*n/2-1-i]
+	}
+
+	re, im := e.fftRe, e.fftIm
+	for m := 0; m < n/2; m++ {
+		a, b := u[2*m], u[n-1-2*m]
+		s, c := math.Sincos(-math.Pi * float64(m) / float64(n))
+		re[m] = a*c - b*s
+		im[m] = a*s + b*c
+	}
+	fft(re, im, false)
+
+	scale := 2.0 / float64(n)
+	for k := 0; k < n/2; k++ {
+		s, c := math.Sincos(-math.Pi * (float64(k) + 0.25) / float64(n))
+		dr := re[k]*c - im[k]*s
+		di := re[k]*s + im[k]*c
+		out[2*k] = dr * scale
+		out[n-1-2*k] = -di * scale
+	}
+}
+
+// Number of bits needed to represent v
+func ilog(v int) int {
+	n := 0
+	for v > 0 {
+		n++
+		v >>= 1
+	}
+	return n
+}
+
+// vorbisBitWriter packs values least significant bit first, as Vorbis
+// packets are laid out
+type vorbisBitWriter struct {
+	data  []byte
+	nbits int // Bits used in the last byte, 0 when it is full
+}
+
+func (w *vorbisBitWriter) write(value uint32, bits int) {
+	for i := 0; i < bits; i++ {
+		if w.nbits == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value>>i&1 != 0 {
+			w.data[len(w.data)-1] |= 1 << w.nbits
+		}
+		w.nbits = (w.nbits + 1) % 8
+	}
+}
+
+// Header packets start with their type byte and the "vorbis" signature
+func (w *vorbisBitWriter) writeHeaderType(packetType byte) {
+	w.write(uint32(packetType), 8)
+	for _, b := range []byte("vorbis") {
+		w.write(uint32(b), 8)
+	}
+}
+
+func (w *vorbisBitWriter) bitLen() int {
+	if w.nbits == 0 {
+		return len(w.data) * 8
+	}
+	return (len(w.data)-1)*8 + w.nbits
+}
+
+func (w *vorbisBitWriter) reset() {
+	w.data = w.data[:0]
+	w.nbits = 0
+}
+
+func (w *vorbisBitWriter) bytes() []byte {
+	return append([]byte(nil), w.data...)
+}
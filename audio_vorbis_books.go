@@ -0,0 +1,211 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// vorbisCodebook is a Vorbis codebook: a prefix code over its entries and,
+// for lattice books, the vector each entry stands for
+type vorbisCodebook struct {
+	dimensions int
+	lengths    []int    // Codeword length of each entry
+	codewords  []uint32 // Codewords, bit-reversed for the LSB-first packer
+
+	// Lattice (lookup type 1) books map entry digits in base lookupValues
+	// to minimum + digit*delta; scalar books have lookupValues == 0
+	lookupValues int
+	minimum      int
+	delta        int
+}
+
+// A codebook whose entries are read as plain numbers
+func newScalarCodebook(dimensions int, weights []float64) *vorbisCodebook {
+	lengths := huffmanLengths(weights)
+	return &vorbisCodebook{
+		dimensions: dimensions,
+		lengths:    lengths,
+		codewords:  vorbisCodewords(lengths),
+	}
+}
+
+// A lattice codebook over all vectors of the given dimension with values
+// minimum, minimum+delta, ..., weighted by the product of weight(value)
+func newLatticeCodebook(dimensions, lookupValues, minimum, delta int, weight func(value int) float64) *vorbisCodebook {
+	entries := 1
+	for i := 0; i < dimensions; i++ {
+		entries *= lookupValues
+	}
+
+	weights := make([]float64, entries)
+	for entry := range weights {
+		weights[entry] = 1
+		digits := entry
+		for i := 0; i < dimensions; i++ {
+			weights[entry] *= weight(minimum + digits%lookupValues*delta)
+			digits /= lookupValues
+		}
+	}
+
+	lengths := huffmanLengths(weights)
+	return &vorbisCodebook{
+		dimensions:   dimensions,
+		lengths:      lengths,
+		codewords:    vorbisCodewords(lengths),
+		lookupValues: lookupValues,
+		minimum:      minimum,
+		delta:        delta,
+	}
+}
+
+func (b *vorbisCodebook) writeHeader(w *vorbisBitWriter) {
+	w.write(0x564342, 24) // Sync pattern "BCV"
+	w.write(uint32(b.dimensions), 16)
+	w.write(uint32(len(b.lengths)), 24)
+	w.write(0, 1) // Not ordered
+	w.write(0, 1) // Not sparse: every entry has a codeword
+	for _, length := range b.lengths {
+		w.write(uint32(length-1), 5)
+	}
+
+	if b.lookupValues == 0 {
+		w.write(0, 4)
+		return
+	}
+	w.write(1, 4)
+	w.write(vorbisFloat(b.minimum), 32)
+	w.write(vorbisFloat(b.delta), 32)
+	valueBits := ilog(b.lookupValues - 1)
+	w.write(uint32(valueBits-1), 4)
+	w.write(0, 1) // Values are not cumulative
+	for i := 0; i < b.lookupValues; i++ {
+		w.write(uint32(i), valueBits)
+	}
+}
+
+func (b *vorbisCodebook) writeEntry(w *vorbisBitWriter, entry int) {
+	w.write(b.codewords[entry], b.lengths[entry])
+}
+
+// Write values as consecutive vectors of a lattice book. Every value must
+// lie on the lattice.
+func (b *vorbisCodebook) writeVectors(w *vorbisBitWriter, values []int) {
+	for i := 0; i < len(values); i += b.dimensions {
+		entry := 0
+		for k := b.dimensions - 1; k >= 0; k-- {
+			entry = entry*b.lookupValues + (values[i+k]-b.minimum)/b.delta
+		}
+		b.writeEntry(w, entry)
+	}
+}
+
+// Pack an integer in the Vorbis float format: a 21-bit mantissa, a sign bit
+// and an exponent biased by 788
+func vorbisFloat(v int) uint32 {
+	var sign uint32
+	if v < 0 {
+		sign = 1 << 31
+		v = -v
+	}
+	return sign | 788<<21 | uint32(v)
+}
+
+// Huffman code lengths for the given symbol weights. Lengths are kept within
+// the 32 bits Vorbis allows by flattening the weights when necessary.
+func huffmanLengths(weights []float64) []int {
+	n := len(weights)
+	w := append([]float64(nil), weights...)
+	for {
+		lengths := huffmanTree(w)
+		longest := 0
+		for _, l := range lengths {
+			longest = max(longest, l)
+		}
+		if n < 2 || longest <= 24 {
+			return lengths
+		}
+		for i := range w {
+			w[i] = math.Sqrt(w[i])
+		}
+	}
+}
+
+func huffmanTree(weights []float64) []int {
+	n := len(weights)
+	if n == 1 {
+		return []int{1}
+	}
+
+	// Nodes 0..n-1 are leaves; merged nodes are appended after them
+	nodeWeight := append([]float64(nil), weights...)
+	parent := make([]int, n, 2*n-1)
+	active := make([]int, n)
+	for i := range active {
+		active[i] = i
+	}
+
+	for len(active) > 1 {
+		sort.SliceStable(active, func(a, b int) bool {
+			return nodeWeight[active[a]] < nodeWeight[active[b]]
+		})
+		a, b := active[0], active[1]
+		node := len(nodeWeight)
+		nodeWeight = append(nodeWeight, nodeWeight[a]+nodeWeight[b])
+		parent = append(parent, -1)
+		parent[a], parent[b] = node, node
+		active = append(active[2:], node)
+	}
+
+	lengths := make([]int, n)
+	for i := range lengths {
+		for node := i; parent[node] >= 0; node = parent[node] {
+			lengths[i]++
+		}
+	}
+	return lengths
+}
+
+// Assign codewords to entries in order, each taking the lowest free
+// codeword of its length, as the Vorbis spec requires. The codewords are
+// returned bit-reversed, ready to be written LSB first.
+func vorbisCodewords(lengths []int) []uint32 {
+	var marker [33]uint32
+	codewords := make([]uint32, len(lengths))
+
+	for i, length := range lengths {
+		entry := marker[length]
+		codewords[i] = entry
+
+		// Move the marker of this length, and of shorter ones that pointed
+		// at the node just taken, to the next free node
+		for j := length; j > 0; j-- {
+			if marker[j]&1 != 0 {
+				if j == 1 {
+					marker[1]++
+				} else {
+					marker[j] = marker[j-1] << 1
+				}
+				break
+			}
+			marker[j]++
+		}
+
+		// Longer markers dangling from the taken node move under the new one
+		for j := length + 1; j < 33; j++ {
+			if marker[j]>>1 != entry {
+				break
+			}
+			entry = marker[j]
+			marker[j] = marker[j-1] << 1
+		}
+	}
+
+	for i, length := range lengths {
+		var reversed uint32
+		for j := 0; j < length; j++ {
+			reversed = reversed<<1 | codewords[i]>>j&1
+		}
+		codewords[i] = reversed
+	}
+	return codewords
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// oggPage is a parsed Ogg page
+type oggPage struct {
+	flags    byte
+	granule  int64
+	serial   uint32
+	sequence uint32
+	segments []byte
+	body     []byte
+	crcOK    bool
+}
+
+// Split an Ogg stream into pages, checking each page's CRC
+func parseOggPages(t *testing.T, data []byte) []oggPage {
+	t.Helper()
+	var pages []oggPage
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" || data[4] != 0 {
+			t.Fatalf("page %d: bad page header", len(pages))
+		}
+		count := int(data[26])
+		size := 27 + count
+		if len(data) < size {
+			t.Fatalf("page %d: truncated segment table", len(pages))
+		}
+		segments := data[27:size]
+		for _, n := range segments {
+			size += int(n)
+		}
+		if len(data) < size {
+			t.Fatalf("page %d: truncated body", len(pages))
+		}
+
+		page := append([]byte{}, data[:size]...)
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		pages = append(pages, oggPage{
+			flags:    data[5],
+			granule:  int64(binary.LittleEndian.Uint64(data[6:])),
+			serial:   binary.LittleEndian.Uint32(data[14:]),
+			sequence: binary.LittleEndian.Uint32(data[18:]),
+			segments: segments,
+			body:     data[27+count : size],
+			crcOK:    oggCRC(0, page) == crc,
+		})
+		data = data[size:]
+	}
+	return pages
+}
+
+// Signal to noise ratio of a decoded signal against the original, in dB
+func snr(original, decoded []int16) float64 {
+	var signal, noise float64
+	for i := range original {
+		s, d := float64(original[i]), float64(decoded[i])
+		signal += s * s
+		noise += (s - d) * (s - d)
+	}
+	return 10 * math.Log10(signal/math.Max(noise, 1))
+}
+
+// Interleaved 16-bit frames of a voiced sound: a gliding 140 Hz
+// fundamental with harmonics, a syllable-rate envelope and a little noise
+func speechInt16(sampleRate, channels int, seconds float64) []int16 {
+	frames := int(seconds * float64(sampleRate))
+	samples := make([]int16, frames*channels)
+	seed := uint32(1)
+	for i := 0; i < frames; i++ {
+		t := float64(i) / float64(sampleRate)
+		f0 := 140 + 30*math.Sin(2*math.Pi*0.7*t)
+		var v float64
+		for h := 1; h <= 20; h++ {
+			v += math.Sin(2*math.Pi*f0*float64(h)*t) / float64(h)
+		}
+		seed = seed*1664525 + 1013904223
+		v = 0.15*v*(0.6+0.4*math.Sin(2*math.Pi*3*t)) + 0.01*(float64(seed>>8)/(1<<24)-0.5)
+		for ch := 0; ch < channels; ch++ {
+			samples[i*channels+ch] = floatToInt16(v)
+		}
+	}
+	return samples
+}
+
+// Encode speech-like audio, decode it with the decoder used for playback
+// and compare. The granule position of the last page must trim the
+// decoded audio to the encoded length exactly.
+func TestOggVorbisRoundTrip(t *testing.T) {
+	tests := []struct {
+		sampleRate, channels, bitrate int
+		seconds                       float64
+		minSNR                        float64 // dB; 0 skips the check
+	}{
+		{44100, 1, 64, 2, 24},
+		{48000, 2, 128, 2, 24},
+		{16000, 1, 32, 2, 18},
+		{44100, 1, 32, 2, 17},
+		{44100, 1, 64, 0.01, 0}, // Shorter than one block
+		{44100, 1, 64, 0, 0},    // No audio at all
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		samples := speechInt16(tt.sampleRate, tt.channels, tt.seconds)
+		var buf bytes.Buffer
+		if err := writeOggVorbis(&buf, samples, tt.sampleRate, tt.channels, tt.bitrate); err != nil {
+			t.Fatalf("writeOggVorbis: %v", err)
+		}
+		path := filepath.Join(dir, "speech.ogg")
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		name := fmt.Sprintf("%d Hz, %d ch, %d kbps, %gs", tt.sampleRate, tt.channels, tt.bitrate, tt.seconds)
+		decoded, sampleRate, channels, err := readOggData(path)
+		if err != nil {
+			t.Fatalf("%s: decoding: %v", name, err)
+		}
+		if sampleRate != tt.sampleRate || channels != tt.channels {
+			t.Errorf("%s: decoded as %d Hz, %d channels", name, sampleRate, channels)
+		}
+		if len(decoded) != len(samples) {
+			t.Errorf("%s: decoded %d samples, want %d", name, len(decoded), len(samples))
+			continue
+		}
+		if got := snr(samples, decoded); tt.minSNR > 0 && got < tt.minSNR {
+			t.Errorf("%s: SNR %.1f dB, want at least %.0f dB", name, got, tt.minSNR)
+		}
+	}
+}
+
+// The stream stays close to the requested average bitrate
+func TestOggVorbisBitrate(t *testing.T) {
+	for _, bitrate := range []int{32, 48, 64} {
+		var buf bytes.Buffer
+		if err := writeOggVorbis(&buf, speechInt16(44100, 1, 4), 44100, 1, bitrate); err != nil {
+			t.Fatal(err)
+		}
+		kbps := float64(buf.Len()) * 8 / 4 / 1000
+		if kbps < float64(bitrate)*0.75 || kbps > float64(bitrate)*1.2 {
+			t.Errorf("%d kbps requested, got %.1f kbps", bitrate, kbps)
+		}
+	}
+}
+
+// Pages carry valid checksums, consecutive sequence numbers and the flags
+// and granule positions the Ogg and Vorbis specs require
+func TestOggPages(t *testing.T) {
+	const sampleRate, channels = 44100, 2
+	samples := speechInt16(sampleRate, channels, 3)
+	frames := int64(len(samples) / channels)
+	var buf bytes.Buffer
+	if err := writeOggVorbis(&buf, samples, sampleRate, channels, 128); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := parseOggPages(t, buf.Bytes())
+	if len(pages) < 4 {
+		t.Fatalf("got %d pages, want headers and several audio pages", len(pages))
+	}
+	lastGranule := int64(0)
+	for i, page := range pages {
+		if !page.crcOK {
+			t.Errorf("page %d: CRC mismatch", i)
+		}
+		if page.sequence != uint32(i) || page.serial != pages[0].serial {
+			t.Errorf("page %d: sequence %d, serial %x", i, page.sequence, page.serial)
+		}
+
+		wantFlags := byte(0)
+		if i == 0 {
+			wantFlags |= oggFirstPage
+		}
+		if i == len(pages)-1 {
+			wantFlags |= oggLastPage
+		}
+		if i > 0 && pages[i-1].segments[len(pages[i-1].segments)-1] == 255 {
+			wantFlags |= oggContinued
+		}
+		if page.flags != wantFlags {
+			t.Errorf("page %d: flags %#x, want %#x", i, page.flags, wantFlags)
+		}
+
+		// Pages on which no packet ends have granule position -1
+		ends := false
+		for _, n := range page.segments {
+			ends = ends || n < 255
+		}
+		if !ends {
+			if page.granule != -1 {
+				t.Errorf("page %d: no packet ends but granule is %d", i, page.granule)
+			}
+			continue
+		}
+		if page.granule < lastGranule || page.granule > frames {
+			t.Errorf("page %d: granule %d after %d, stream has %d frames", i, page.granule, lastGranule, frames)
+		}
+		lastGranule = page.granule
+	}
+
+	// The identification header is alone on the first page, and the
+	// comment and setup headers end the second, all at granule 0
+	if len(pages[0].segments) != 1 || pages[0].body[0] != 1 || pages[0].granule != 0 {
+		t.Errorf("first page: %d segments, packet type %d, granule %d", len(pages[0].segments), pages[0].body[0], pages[0].granule)
+	}
+	if pages[1].body[0] != 3 || pages[1].granule != 0 {
+		t.Errorf("second page: packet type %d, granule %d", pages[1].body[0], pages[1].granule)
+	}
+	if last := pages[len(pages)-1].granule; last != frames {
+		t.Errorf("final granule %d, want %d", last, frames)
+	}
+}
+
+// The headers are fixed by the stream parameters, so any change to them
+// shows up here. Update testdata/vorbis_setup.golden deliberately when
+// the codebooks or floor layout change.
+func TestVorbisHeaders(t *testing.T) {
+	e := newVorbisEncoder(44100, 2, 64)
+
+	identification := []byte{
+		0x01, 'v', 'o', 'r', 'b', 'i', 's',
+		0, 0, 0, 0, // Version
+		2,                      // Channels
+		0x44, 0xAC, 0x00, 0x00, // 44100 Hz
+		0, 0, 0, 0, // Maximum bitrate
+		0x00, 0xFA, 0x00, 0x00, // Nominal bitrate, 64000
+		0, 0, 0, 0, // Minimum bitrate
+		0xB8, // Block sizes 2^8 and 2^11
+		0x01, // Framing
+	}
+	if got := e.identificationHeader(); !bytes.Equal(got, identification) {
+		t.Errorf("identification header = % x\nwant                    % x", got, identification)
+	}
+
+	comment := []byte{0x03, 'v', 'o', 'r', 'b', 'i', 's', 8, 0, 0, 0}
+	comment = append(comment, vorbisVendor...)
+	comment = append(comment, 0, 0, 0, 0, 0x01)
+	if got := e.commentHeader(); !bytes.Equal(got, comment) {
+		t.Errorf("comment header = % x\nwant             % x", got, comment)
+	}
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "vorbis_setup.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.setupHeader(); !bytes.Equal(got, golden) {
+		t.Errorf("setup header (%d bytes) differs from testdata/vorbis_setup.golden (%d bytes)", len(got), len(golden))
+	}
+	if !bytes.Equal(newVorbisEncoder(16000, 1, 32).setupHeader(), golden) {
+		t.Error("setup header depends on the stream parameters")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Capture pipeline timing
+const (
+	captureBufferTime = 2 * time.Second        // Audio the ring buffer holds before dropping
+	meterBufferTime   = 250 * time.Millisecond // Audio the meter ring holds
+	writerInterval    = 20 * time.Millisecond  // How often the writer drains the ring
+	meterInterval     = 50 * time.Millisecond  // How often levels are sent to the UI
+
+	// How often the header of the recording file is brought up to date, so
+	// a crash loses at most this much of a recording
+	headerSyncInterval = 2 * time.Second
+)
+
+// audioLevelMsg carries input levels from the metering goroutine to Update
+type audioLevelMsg struct {
+	envelope []envelopeSlice // Waveform slices completed since the last message
+	vuMeter  VUMeterData
+}
+
+// Set up the rings between the input callback and the writer and metering
+// goroutines, and start both goroutines. Must be called before the input
+// stream is started.
+func (d *AudioDevice) startCapture(sampleRate, channels int) {
+	samplesPerSecond := float64(sampleRate * channels)
+	d.captureRing = newRingBuffer(int(samplesPerSecond * captureBufferTime.Seconds()))
+	d.meterRing = newRingBuffer(int(samplesPerSecond * meterBufferTime.Seconds()))
+	d.levels = make(chan audioLevelMsg, 1)
+	d.stopCapture = make(chan struct{})
+
+	d.captureWG.Add(2)
+	go d.runWriter(channels)
+	go d.runMeter(sampleRate, channels)
+}
+
+// Stop the capture goroutines once the input stream has stopped. The writer
+// drains the ring and the resampler before it exits.
+func (d *AudioDevice) finishCapture() {
+	if d.stopCapture == nil {
+		return
+	}
+	close(d.stopCapture)
+	d.captureWG.Wait()
+	d.stopCapture = nil
+
+	if dropped := d.captureRing.dropped.Load(); dropped > 0 {
+		log.Printf("Dropped %d input samples: disk writer fell behind", dropped)
+	}
+}
+
+// Input callback: hand the samples to the writer, unless the recording is
+// paused or waiting for voice, and to the meter. Runs on the audio thread,
+// so it must not block, allocate or do I/O.
+func (d *AudioDevice) processInput(in []float32) {
+	if d.captureRing == nil {
+		return
+	}
+	voiced := d.voiceGate == nil || d.voiceGate.process(in)
+	if voiced && !d.capturePaused.Load() {
+		d.captureRing.push(in)
+	}
+	d.meterRing.push(in)
+}
+
+// Drain the capture ring into the recording file until capture stops
+func (d *AudioDevice) runWriter(channels int) {
+	defer d.captureWG.Done()
+
+	if d.preRoll != nil {
+		d.writePreRoll()
+	}
+
+	buf := make([]float32, 8192*channels)
+	drain := func() {
+		for {
+			n := d.captureRing.pop(buf)
+			if n == 0 {
+				return
+			}
+			d.resampleBuf = d.resampler.process(d.resampleBuf[:0], buf[:n])
+			d.writeCapture(d.resampleBuf)
+		}
+	}
+
+	ticker := time.NewTicker(writerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stopCapture:
+			drain()
+			d.writeCapture(d.resampler.flush(nil))
+			return
+		case now := <-ticker.C:
+			drain()
+			d.syncHeader(now)
+		}
+	}
+}
+
+// Patch the header of the recording in progress to cover the audio written
+// so far and flush it to disk
+func (d *AudioDevice) syncHeader(now time.Time) {
+	if now.Sub(d.headerSynced) < headerSyncInterval {
+		return
+	}
+	d.headerSynced = now
+
+	if err := finalizeWAVHeader(d.recordingFile); err != nil {
+		log.Printf("Error updating WAV header: %v", err)
+		return
+	}
+	if err := d.recordingFile.Sync(); err != nil {
+		log.Printf("Error syncing recording file: %v", err)
+	}
+}
+
+// Compute input levels from the meter ring and send them to the UI until
+// capture stops
+func (d *AudioDevice) runMeter(sampleRate, channels int) {
+	defer d.captureWG.Done()
+	defer close(d.levels)
+
+	var meter VUMeterData
+	var envelope []envelopeSlice
+	builder := newEnvelopeBuilder(sampleRate, channels)
+	buf := make([]float32, len(d.meterRing.buf))
+	ticker := time.NewTicker(meterInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-d.stopCapture:
+			return
+		case now := <-ticker.C:
+			n := d.meterRing.pop(buf)
+			if n == 0 {
+				continue
+			}
+			meter.update(buf[:n], channels, now.Sub(last))
+			envelope = builder.add(envelope, buf[:n])
+			envelope = envelope[max(0, len(envelope)-waveformHistory):]
+			last = now
+
+			// If the UI has not taken the previous update, keep the slices
+			// for the next one
+			select {
+			case d.levels <- audioLevelMsg{envelope: envelope, vuMeter: meter}:
+				envelope = nil
+			default:
+			}
+		}
+	}
+}
+
+// Wait for the next level update from the recording in progress
+func listenForLevels(levels chan audioLevelMsg) tea.Cmd {
+	if levels == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg, ok := <-levels
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
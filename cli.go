@@ -0,0 +1,624 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// cliCommand is a subcommand for scripting memos without the UI
+type cliCommand struct {
+	name    string
+	args    string // Positional arguments, for the usage line
+	summary string
+	flags   func(fs *flag.FlagSet, opts *cliOptions)
+	run     func(c *cli, args []string) error
+}
+
+// cliOptions holds the flags of all subcommands
+type cliOptions struct {
+	json     bool
+	duration time.Duration
+	name     string
+	tags     stringList
+	to       string
+	remove   bool
+}
+
+// cli runs a subcommand against the config and memo store the UI would
+// use. Only record and play open the audio backend, through audioModel.
+type cli struct {
+	config Config
+	store  MemoStore
+	memos  []Memo // Newest first, as in the UI
+	opts   cliOptions
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Printed in place of the log for failures the log explains
+const seeLog = "see ~/" + ConfigDir + "/" + LogFile
+
+var cliCommands = []cliCommand{
+	{
+		name:    "list",
+		summary: "List memos, newest first",
+		flags: func(fs *flag.FlagSet, opts *cliOptions) {
+			fs.Var(&opts.tags, "tag", "only memos with this `tag` (repeatable)")
+		},
+		run: (*cli).list,
+	},
+	{
+		name:    "record",
+		summary: "Record a memo until the duration is up or interrupted",
+		flags: func(fs *flag.FlagSet, opts *cliOptions) {
+			fs.DurationVar(&opts.duration, "duration", 0, "stop after this long, e.g. 30s (default until interrupted)")
+			fs.StringVar(&opts.name, "name", "", "memo `name` (default from the file name)")
+			fs.Var(&opts.tags, "tag", "add this `tag` (repeatable)")
+		},
+		run: (*cli).record,
+	},
+	{
+		name:    "play",
+		args:    "<id>",
+		summary: "Play a memo to the end or until interrupted",
+		run:     (*cli).play,
+	},
+	{
+		name:    "export",
+		args:    "<id>",
+		summary: "Copy a memo's audio file",
+		flags: func(fs *flag.FlagSet, opts *cliOptions) {
+			fs.StringVar(&opts.to, "to", ".", "destination file or directory")
+		},
+		run: (*cli).export,
+	},
+	{
+		name:    "tag",
+		args:    "<id> <tag>...",
+		summary: "Add tags to a memo",
+		flags: func(fs *flag.FlagSet, opts *cliOptions) {
+			fs.BoolVar(&opts.remove, "remove", false, "remove the tags instead")
+		},
+		run: (*cli).tag,
+	},
+	{
+		name:    "rm",
+		args:    "<id>...",
+		summary: "Delete memos with their audio and transcripts",
+		run:     (*cli).rm,
+	},
+	{
+		name:    "info",
+		args:    "<id>",
+		summary: "Show a memo's details and transcript",
+		run:     (*cli).info,
+	},
+}
+
+// Run the subcommand in args, writing results to stdout and errors to
+// stderr. Returns the exit status: 0 on success, 1 on failure, 2 on
+// misuse.
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printCommands(stdout)
+		return 0
+	}
+
+	var command *cliCommand
+	for i := range cliCommands {
+		if cliCommands[i].name == args[0] {
+			command = &cliCommands[i]
+		}
+	}
+	if command == nil {
+		fmt.Fprintf(stderr, "%s: unknown command %q\n\n", AppName, args[0])
+		printCommands(stderr)
+		return 2
+	}
+
+	c := &cli{stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet(AppName+" "+command.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&c.opts.json, "json", false, "print results as JSON")
+	if command.flags != nil {
+		command.flags(fs, &c.opts)
+	}
+	fs.Usage = func() {
+		usage := strings.Join(strings.Fields(AppName+" "+command.name+" "+command.args), " ")
+		fmt.Fprintf(stderr, "Usage: %s [flags]\n\n%s\n\nFlags:\n", usage, command.summary)
+		fs.PrintDefaults()
+	}
+	rest, err := parseInterspersed(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	c.config = loadConfig()
+	if err := os.MkdirAll(c.config.MemosPath, 0755); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", AppName, err)
+		return 1
+	}
+	c.store, err = openMemoStore(c.config.MemosPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", AppName, err)
+		return 1
+	}
+	c.memos = storedMemos(c.store, c.config.MemosPath)
+
+	if err := command.run(c, rest); err != nil {
+		fmt.Fprintf(stderr, "%s %s: %v\n", AppName, command.name, err)
+		var usage usageError
+		if errors.As(err, &usage) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [arguments] [flags]\n\n", AppName)
+	fmt.Fprintf(w, "Without a command the interactive UI starts. Commands:\n\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, command := range cliCommands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", command.name, command.args, command.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nEvery command takes --json. Memos are named by ID, a unique start of\nan ID, or file name.\n")
+}
+
+// Parse flags wherever they appear among the arguments, so they can
+// follow positional ones, and return the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// usageError is a command called with the wrong arguments
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// memoOutput is a memo as printed by --json
+type memoOutput struct {
+	Memo
+	Path       string `json:"path"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+func (c *cli) output(memo Memo) memoOutput {
+	return memoOutput{Memo: memo, Path: filepath.Join(c.config.MemosPath, memo.Filename)}
+}
+
+// A model holding the audio backend and memos, for the commands that
+// record or play through the UI's code
+func (c *cli) audioModel() *Model {
+	return &Model{
+		state:         StateViewing,
+		config:        c.config,
+		backend:       newAudioBackend(c.config),
+		store:         c.store,
+		memos:         c.memos,
+		lastUpdate:    time.Now(),
+		playbackSpeed: 1,
+	}
+}
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// Find a memo by ID, by file name with or without its extension, or by
+// the start of an ID if only one memo's ID starts that way
+func (c *cli) findMemo(ref string) (int, error) {
+	found := -1
+	for i, memo := range c.memos {
+		if memo.ID != ref {
+			continue
+		}
+		if found >= 0 {
+			return -1, fmt.Errorf("more than one memo has ID %s; use its file name", ref)
+		}
+		found = i
+	}
+	if found >= 0 {
+		return found, nil
+	}
+
+	for i, memo := range c.memos {
+		if memo.Filename == ref || strings.TrimSuffix(memo.Filename, filepath.Ext(memo.Filename)) == ref {
+			return i, nil
+		}
+	}
+
+	matches := 0
+	for i, memo := range c.memos {
+		if ref != "" && strings.HasPrefix(memo.ID, ref) {
+			found = i
+			matches++
+		}
+	}
+	switch {
+	case matches > 1:
+		return -1, fmt.Errorf("%d memos have IDs starting with %s; give more of the ID", matches, ref)
+	case matches == 1:
+		return found, nil
+	}
+	return -1, fmt.Errorf("no memo %q", ref)
+}
+
+// Find the memo named by the only positional argument
+func (c *cli) memoArg(args []string) (int, error) {
+	if len(args) != 1 {
+		return -1, usageError(fmt.Sprintf("expected one memo, got %d arguments", len(args)))
+	}
+	return c.findMemo(args[0])
+}
+
+func (c *cli) list(args []string) error {
+	if len(args) > 0 {
+		return usageError("list takes no arguments")
+	}
+
+	var memos []Memo
+	for _, memo := range c.memos {
+		if hasTags(memo, c.opts.tags) {
+			memos = append(memos, memo)
+		}
+	}
+
+	if c.opts.json {
+		outputs := make([]memoOutput, 0, len(memos))
+		for _, memo := range memos {
+			outputs = append(outputs, c.output(memo))
+		}
+		return c.printJSON(outputs)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tLENGTH\tNAME\tTAGS")
+	for _, memo := range memos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", memo.ID, memo.Created.Format("2006-01-02 15:04"),
+			formatDuration(time.Duration(memo.Duration*float64(time.Second))), memo.Name, strings.Join(memo.Tags, ", "))
+	}
+	return tw.Flush()
+}
+
+// Whether a memo has all the given tags
+func hasTags(memo Memo, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range memo.Tags {
+			found = found || t == tag
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *cli) record(args []string) error {
+	if len(args) > 0 {
+		return usageError("record takes no arguments")
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	m := c.audioModel()
+	save := m.startRecording()
+	if !m.recording {
+		return fmt.Errorf("could not start recording; %s", seeLog)
+	}
+	if !c.opts.json {
+		fmt.Fprintln(c.stderr, "Recording, press Ctrl+C to stop")
+	}
+
+	// Keep time and stop on silence as the UI's tick does
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for m.recording {
+		select {
+		case <-interrupted:
+			save = m.stopRecording()
+		case now := <-ticker.C:
+			if !m.voiceGateClosed() {
+				m.recordingTime += now.Sub(m.lastUpdate)
+			}
+			m.lastUpdate = now
+			save = m.checkSilenceTimeout()
+			if m.recording && c.opts.duration > 0 && m.recordingTime >= c.opts.duration {
+				save = m.stopRecording()
+			}
+		}
+	}
+	if save == nil {
+		return fmt.Errorf("recording was not saved; %s", seeLog)
+	}
+	msg := save().(recordingSavedMsg)
+	if msg.notice != "" {
+		fmt.Fprintln(c.stderr, msg.notice)
+	}
+
+	memo := msg.memo
+	if c.opts.name != "" {
+		memo.Name = c.opts.name
+	}
+	for _, tag := range c.opts.tags {
+		memo.Tags = addUnique(memo.Tags, tag)
+	}
+	saved := []Memo{memo}
+	if msg.original != nil {
+		saved = append(saved, *msg.original)
+	}
+	if err := c.store.Update(saved, nil); err != nil {
+		return err
+	}
+
+	output := c.output(memo)
+	if transcript, ok := c.transcribe(memo, interrupted); ok {
+		output.Transcript = transcript.Text()
+	}
+	if c.opts.json {
+		return c.printJSON(output)
+	}
+	fmt.Fprintf(c.stdout, "Recorded %s (%s) as %s\n", memo.Name,
+		formatDuration(time.Duration(memo.Duration*float64(time.Second))), memo.ID)
+	return nil
+}
+
+// Transcribe a new memo with the configured engine, if any, and save the
+// transcript next to it. Unlike the UI, which transcribes in the
+// background, this waits for the engine; an interrupt skips it.
+func (c *cli) transcribe(memo Memo, interrupted <-chan os.Signal) (Transcript, bool) {
+	transcriber := newTranscriber(c.config)
+	if transcriber == nil {
+		return Transcript{}, false
+	}
+	if !c.opts.json {
+		fmt.Fprintln(c.stderr, "Transcribing, press Ctrl+C to skip")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	msg := runTranscriber(ctx, transcriber, c.config.MemosPath, memo.Filename)().(transcriptMsg)
+	if msg.err != nil {
+		log.Printf("Error transcribing %s: %v", memo.Filename, msg.err)
+		if ctx.Err() == nil {
+			fmt.Fprintf(c.stderr, "Transcription failed: %v\n", msg.err)
+		}
+		return Transcript{}, false
+	}
+	if err := saveTranscript(c.config.MemosPath, memo.Filename, msg.transcript); err != nil {
+		fmt.Fprintf(c.stderr, "Saving transcript failed: %v\n", err)
+		return Transcript{}, false
+	}
+	return msg.transcript, true
+}
+
+func (c *cli) play(args []string) error {
+	i, err := c.memoArg(args)
+	if err != nil {
+		return err
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	m := c.audioModel()
+	m.selectedIdx = i
+	m.startPlayback()
+	if !m.playing {
+		return fmt.Errorf("could not play %s; %s", m.memos[i].Filename, seeLog)
+	}
+	defer m.stopPlayback()
+	if !c.opts.json {
+		fmt.Fprintf(c.stdout, "Playing %s (%s)\n", m.memos[i].Name, formatDuration(m.audioDevice.playbackLength()))
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for m.audioDevice.playbackPos.Load() < int64(len(m.audioDevice.playbackData)) {
+		select {
+		case <-interrupted:
+			return nil
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func (c *cli) export(args []string) error {
+	i, err := c.memoArg(args)
+	if err != nil {
+		return err
+	}
+	memo := c.memos[i]
+
+	dst := c.opts.to
+	if info, err := os.Stat(dst); err == nil && info.IsDir() {
+		dst = filepath.Join(dst, memo.Filename)
+	} else if ext := filepath.Ext(dst); !strings.EqualFold(ext, filepath.Ext(memo.Filename)) {
+		return fmt.Errorf("%s is a %s file and is copied as is; give a %s path or a directory",
+			memo.Filename, filepath.Ext(memo.Filename), filepath.Ext(memo.Filename))
+	}
+	if err := copyFile(filepath.Join(c.config.MemosPath, memo.Filename), dst); err != nil {
+		return err
+	}
+
+	if c.opts.json {
+		return c.printJSON(map[string]string{"id": memo.ID, "path": dst})
+	}
+	fmt.Fprintln(c.stdout, dst)
+	return nil
+}
+
+func (c *cli) tag(args []string) error {
+	if len(args) < 2 {
+		return usageError("expected a memo and at least one tag")
+	}
+	i, err := c.findMemo(args[0])
+	if err != nil {
+		return err
+	}
+
+	memo := c.memos[i]
+	memo.Tags = append([]string{}, memo.Tags...)
+	for _, tag := range args[1:] {
+		if c.opts.remove {
+			memo.Tags = removeValue(memo.Tags, tag)
+		} else {
+			memo.Tags = addUnique(memo.Tags, tag)
+		}
+	}
+	if err := c.store.Update([]Memo{memo}, nil); err != nil {
+		return err
+	}
+
+	if c.opts.json {
+		return c.printJSON(c.output(memo))
+	}
+	fmt.Fprintln(c.stdout, strings.Join(memo.Tags, ", "))
+	return nil
+}
+
+func addUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func removeValue(values []string, value string) []string {
+	kept := values[:0]
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func (c *cli) rm(args []string) error {
+	if len(args) == 0 {
+		return usageError("expected at least one memo")
+	}
+
+	// Find them all before deleting any
+	var memos []Memo
+	for _, ref := range args {
+		i, err := c.findMemo(ref)
+		if err != nil {
+			return err
+		}
+		memos = append(memos, c.memos[i])
+	}
+
+	// The same deletion as the UI's, so transcripts and cached peaks go too
+	deleted, err := deleteMemos(c.store, c.config.MemosPath, memos)
+	var removed []Memo
+	for _, memo := range memos {
+		for _, filename := range deleted {
+			if memo.Filename == filename {
+				removed = append(removed, memo)
+			}
+		}
+	}
+
+	if c.opts.json {
+		outputs := make([]memoOutput, 0, len(removed))
+		for _, memo := range removed {
+			outputs = append(outputs, c.output(memo))
+		}
+		if jsonErr := c.printJSON(outputs); err == nil {
+			err = jsonErr
+		}
+		return err
+	}
+	for _, memo := range removed {
+		fmt.Fprintf(c.stdout, "Deleted %s\n", memo.Name)
+	}
+	return err
+}
+
+func (c *cli) info(args []string) error {
+	i, err := c.memoArg(args)
+	if err != nil {
+		return err
+	}
+
+	output := c.output(c.memos[i])
+	if transcript, err := loadTranscript(c.config.MemosPath, output.Filename); err == nil {
+		output.Transcript = transcript.Text()
+	}
+	if c.opts.json {
+		return c.printJSON(output)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", output.ID)
+	fmt.Fprintf(tw, "Name:\t%s\n", output.Name)
+	fmt.Fprintf(tw, "File:\t%s\n", output.Path)
+	fmt.Fprintf(tw, "Format:\t%s\n", output.Format)
+	fmt.Fprintf(tw, "Length:\t%s\n", formatDuration(time.Duration(output.Duration*float64(time.Second))))
+	fmt.Fprintf(tw, "Size:\t%s\n", formatBytes(output.Size))
+	fmt.Fprintf(tw, "Created:\t%s\n", output.Created.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(tw, "Tags:\t%s\n", strings.Join(output.Tags, ", "))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if output.Transcript != "" {
+		fmt.Fprintf(c.stdout, "\n%s\n", output.Transcript)
+	}
+	return nil
+}
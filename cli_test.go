@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args       []string
+		positional []string
+		json       bool
+		tags       []string
+	}{
+		{nil, nil, false, nil},
+		{[]string{"a", "b"}, []string{"a", "b"}, false, nil},
+		{[]string{"--json", "a"}, []string{"a"}, true, nil},
+		{[]string{"a", "--json"}, []string{"a"}, true, nil},
+		{[]string{"a", "--tag", "x", "b", "--tag=y"}, []string{"a", "b"}, false, []string{"x", "y"}},
+		{[]string{"--", "--json"}, []string{"--json"}, false, nil},
+		{[]string{"a", "--", "-b"}, []string{"a", "-b"}, false, nil},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var opts cliOptions
+		fs.BoolVar(&opts.json, "json", false, "")
+		fs.Var(&opts.tags, "tag", "")
+
+		positional, err := parseInterspersed(fs, tt.args)
+		if err != nil {
+			t.Errorf("parseInterspersed(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(positional, tt.positional) || opts.json != tt.json || !reflect.DeepEqual([]string(opts.tags), tt.tags) {
+			t.Errorf("parseInterspersed(%q) = %q, json %v, tags %q; want %q, json %v, tags %q",
+				tt.args, positional, opts.json, opts.tags, tt.positional, tt.json, tt.tags)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, err := parseInterspersed(fs, []string{"a", "--unknown"}); err == nil {
+		t.Error("parseInterspersed accepted an unknown flag")
+	}
+}
+
+func TestFindMemo(t *testing.T) {
+	c := &cli{memos: []Memo{
+		{ID: "1700000000111", Filename: "memo_a.wav"},
+		{ID: "1700000000222", Filename: "memo_b.mp3"},
+		{ID: "1800000000333", Filename: "memo_c.ogg"},
+		{ID: "dup", Filename: "memo_d.wav"},
+		{ID: "dup", Filename: "memo_e.wav"},
+	}}
+
+	tests := []struct {
+		ref  string
+		want int // -1 for an error
+	}{
+		{"1700000000222", 1},
+		{"memo_b.mp3", 1},
+		{"memo_b", 1},
+		{"18", 2},          // Unique ID prefix
+		{"1700000000", -1}, // Ambiguous prefix
+		{"17000000001", 0}, // Prefix made unique
+		{"dup", -1},        // Shared ID
+		{"memo_e", 4},      // Memos sharing an ID are named by file
+		{"memo_b.wav", -1}, // Wrong extension
+		{"2", -1},          // No such ID
+		{"", -1},
+	}
+
+	for _, tt := range tests {
+		got, err := c.findMemo(tt.ref)
+		if tt.want < 0 {
+			if err == nil {
+				t.Errorf("findMemo(%q) = %d, want an error", tt.ref, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("findMemo(%q) = %d, %v; want %d", tt.ref, got, err, tt.want)
+		}
+	}
+}
+
+// A CLI over a temporary memos directory holding two memos, the newer
+// with a transcript
+func testCLI(t *testing.T) (*cli, *bytes.Buffer) {
+	t.Helper()
+	dir := t.TempDir()
+	memos := []Memo{
+		{ID: "2", Filename: "memo_b.wav", Name: "Second", Duration: 2, Created: time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC), Size: 44, Tags: []string{"work"}, Format: "WAV"},
+		{ID: "1", Filename: "memo_a.wav", Name: "First", Duration: 1, Created: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), Size: 44, Tags: []string{}, Format: "WAV"},
+	}
+	for _, memo := range memos {
+		if err := writeWAVFile(filepath.Join(dir, memo.Filename), nil, 44100, 1, 16); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transcript := Transcript{Engine: TranscriberFake, Words: []TranscriptWord{{Text: "hello", End: 0.5}, {Text: "there", Start: 0.5, End: 1}}}
+	if err := saveTranscript(dir, "memo_b.wav", transcript); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openMemoStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Update(memos, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	c := &cli{
+		config: Config{MemosPath: dir},
+		store:  store,
+		memos:  storedMemos(store, dir),
+		stdout: &stdout,
+		stderr: io.Discard,
+	}
+	c.opts.json = true
+	return c, &stdout
+}
+
+// The keys scripts rely on
+var memoJSONKeys = []string{"created", "duration", "filename", "format", "id", "name", "path", "size", "tags"}
+
+func jsonKeys(object map[string]any) []string {
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestListJSON(t *testing.T) {
+	c, stdout := testCLI(t)
+	if err := c.list(nil); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	var memos []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &memos); err != nil {
+		t.Fatalf("list output is not a JSON array: %v\n%s", err, stdout)
+	}
+	if len(memos) != 2 || memos[0]["id"] != "2" || memos[1]["id"] != "1" {
+		t.Fatalf("list = %v, want memos 2 and 1, newest first", memos)
+	}
+	for _, memo := range memos {
+		if keys := jsonKeys(memo); !reflect.DeepEqual(keys, memoJSONKeys) {
+			t.Errorf("memo keys = %q, want %q", keys, memoJSONKeys)
+		}
+	}
+	if memos[1]["path"] != filepath.Join(c.config.MemosPath, "memo_a.wav") {
+		t.Errorf("path = %v", memos[1]["path"])
+	}
+	if tags, ok := memos[1]["tags"].([]any); !ok || len(tags) != 0 {
+		t.Errorf("tags of an untagged memo = %#v, want an empty array", memos[1]["tags"])
+	}
+
+	// An empty list is still an array
+	stdout.Reset()
+	c.opts.tags = stringList{"missing"}
+	if err := c.list(nil); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "[]" {
+		t.Errorf("list of no memos = %s, want []", got)
+	}
+}
+
+func TestInfoJSON(t *testing.T) {
+	c, stdout := testCLI(t)
+	if err := c.info([]string{"2"}); err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	var memo map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &memo); err != nil {
+		t.Fatalf("info output is not a JSON object: %v\n%s", err, stdout)
+	}
+	want := append([]string{"transcript"}, memoJSONKeys...)
+	sort.Strings(want)
+	if keys := jsonKeys(memo); !reflect.DeepEqual(keys, want) {
+		t.Errorf("info keys = %q, want %q", keys, want)
+	}
+	if memo["transcript"] != "hello there" || memo["name"] != "Second" {
+		t.Errorf("info = %v", memo)
+	}
+
+	// No transcript key without a transcript
+	stdout.Reset()
+	if err := c.info([]string{"memo_a"}); err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	memo = nil
+	if err := json.Unmarshal(stdout.Bytes(), &memo); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := memo["transcript"]; ok {
+		t.Errorf("info of a memo without a transcript has one: %v", memo)
+	}
+}
+
+// rm deletes the audio, transcript and cached peaks, as the UI does
+func TestRemove(t *testing.T) {
+	c, _ := testCLI(t)
+	dir := c.config.MemosPath
+	cache := map[string]memoPeaks{"memo_a.wav": {Size: 44}, "memo_b.wav": {Size: 44}}
+	if err := writePeakCache(cache, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.rm([]string{"2"}); err != nil {
+		t.Fatalf("rm: %v", err)
+	}
+	for _, name := range []string{"memo_b.wav", "memo_b" + transcriptSuffix} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not deleted", name)
+		}
+	}
+	peaks := loadPeakCache(dir)
+	if _, ok := peaks["memo_b.wav"]; ok {
+		t.Error("peaks of the deleted memo are still cached")
+	}
+	if _, ok := peaks["memo_a.wav"]; !ok {
+		t.Error("peaks of the other memo were dropped")
+	}
+	if memos := storedMemos(c.store, dir); len(memos) != 1 || memos[0].ID != "1" {
+		t.Errorf("memos left in the store = %+v, want only memo 1", memos)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Noise reduction defaults
+const (
+	DefaultHighPassFrequency = 80.0 // Hz, below the lowest voice fundamentals
+	DefaultNoiseProfileTime  = 1.0  // seconds
+)
+
+// Mains hum frequencies offered in the settings, in Hz; 0 is no hum filter
+var humFrequencies = []float64{0, 50, 60}
+
+// Noise reduction parameters
+const (
+	humHarmonics  = 4    // Hum harmonics notched, counting the fundamental
+	humNotchQ     = 30.0 // Narrow enough to leave the voice around the hum alone
+	highPassQ     = math.Sqrt2 / 2
+	denoiseWindow = 46 * time.Millisecond // STFT window, rounded up to a power of two in frames
+
+	oversubtraction = 2.0  // Multiple of the noise power removed from each bin
+	spectralFloor   = 0.01 // Lowest power gain of a bin, -20 dB, so noise is reduced but not gated
+	gainSmoothing   = 0.5  // Weight of the previous frame's gain, against musical noise
+)
+
+// Run the noise reduction chain over interleaved samples in [-1, 1] in
+// place: a high-pass filter, notches at the mains hum frequency and its
+// harmonics, then spectral subtraction of the noise heard during the
+// first NoiseProfileTime seconds, which are expected to hold no speech
+func reduceNoise(samples []float32, channels, sampleRate int, config Config) {
+	frames := len(samples) / channels
+	x := make([]float64, frames)
+	for c := 0; c < channels; c++ {
+		for i := range x {
+			x[i] = float64(samples[i*channels+c])
+		}
+
+		var filters []*biquad
+		if config.HighPassFrequency > 0 {
+			filters = append(filters, newHighPass(config.HighPassFrequency, sampleRate))
+		}
+		for h := 1; h <= humHarmonics && config.HumFrequency > 0; h++ {
+			if freq := config.HumFrequency * float64(h); freq < float64(sampleRate)/2 {
+				filters = append(filters, newNotch(freq, humNotchQ, sampleRate))
+			}
+		}
+		for _, filter := range filters {
+			for i, v := range x {
+				x[i] = filter.process(v)
+			}
+		}
+
+		spectralSubtract(x, sampleRate, config.NoiseProfileTime)
+
+		for i, v := range x {
+			samples[i*channels+c] = float32(v)
+		}
+	}
+}
+
+// Second-order Butterworth high-pass, from the RBJ audio EQ cookbook
+func newHighPass(freq float64, sampleRate int) *biquad {
+	w := 2 * math.Pi * freq / float64(sampleRate)
+	alpha := math.Sin(w) / (2 * highPassQ)
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return &biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// Notch filter at freq with quality q, from the RBJ audio EQ cookbook
+func newNotch(freq, q float64, sampleRate int) *biquad {
+	w := 2 * math.Pi * freq / float64(sampleRate)
+	alpha := math.Sin(w) / (2 * q)
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return &biquad{
+		b0: 1 / a0,
+		b1: -2 * cos / a0,
+		b2: 1 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// Reduce stationary noise in one channel by spectral subtraction. The
+// noise power of each frequency bin is averaged over the windows in the
+// first profileTime seconds; every window then has that power, times
+// oversubtraction, taken off each bin, down to spectralFloor. Windows are
+// square-root Hann at 50% overlap on both analysis and synthesis, so the
+// audio is reconstructed exactly wherever nothing is subtracted. Audio too
+// short to learn a profile from is left alone.
+func spectralSubtract(x []float64, sampleRate int, profileTime float64) {
+	n := 1
+	for float64(n) < denoiseWindow.Seconds()*float64(sampleRate) {
+		n <<= 1
+	}
+	hop := n / 2
+	bins := n/2 + 1
+
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)))
+	}
+
+	// Pad a window of silence on both sides so every sample is covered by
+	// two windows
+	padded := make([]float64, len(x)+2*n)
+	copy(padded[n:], x)
+	out := make([]float64, len(padded))
+	re, im := make([]float64, n), make([]float64, n)
+
+	spectrum := func(start int) {
+		for i := range re {
+			re[i] = padded[start+i] * window[i]
+			im[i] = 0
+		}
+		fft(re, im, false)
+	}
+
+	noise := make([]float64, bins)
+	profileEnd := min(len(padded), n+int(profileTime*float64(sampleRate)))
+	windows := 0
+	for start := n; start+n <= profileEnd; start += hop {
+		spectrum(start)
+		for k := range noise {
+			noise[k] += re[k]*re[k] + im[k]*im[k]
+		}
+		windows++
+	}
+	if windows == 0 {
+		return
+	}
+	for k := range noise {
+		noise[k] *= oversubtraction / float64(windows)
+	}
+
+	gains := make([]float64, bins)
+	for k := range gains {
+		gains[k] = 1
+	}
+	for start := 0; start+n <= len(padded); start += hop {
+		spectrum(start)
+		for k := range gains {
+			power := re[k]*re[k] + im[k]*im[k]
+			gain := 1.0
+			if noise[k] > 0 {
+				gain = math.Max(1-noise[k]/power, spectralFloor)
+			}
+			gains[k] = gainSmoothing*gains[k] + (1-gainSmoothing)*math.Sqrt(gain)
+
+			// Real input has a mirrored spectrum
+			re[k] *= gains[k]
+			im[k] *= gains[k]
+			if k > 0 && k < n/2 {
+				re[n-k] *= gains[k]
+				im[n-k] *= gains[k]
+			}
+		}
+		fft(re, im, true)
+		for i := range re {
+			out[start+i] += re[i] / float64(n) * window[i]
+		}
+	}
+
+	copy(x, out[n:])
+}
+
+// Reduce noise in the selected memo in the background, written as a new
+// memo, and ask whether to delete the original
+func (m *Model) denoiseSelected() tea.Cmd {
+	if m.selectedIdx < 0 || m.selectedIdx >= len(m.memos) {
+		return nil
+	}
+	if !m.startEdit("Reducing noise") {
+		return nil
+	}
+
+	memo, memosPath, config := m.memos[m.selectedIdx], m.config.MemosPath, m.config
+	return func() tea.Msg {
+		base := strings.TrimSuffix(memo.Filename, filepath.Ext(memo.Filename))
+		dstPath := uniquePath(filepath.Join(memosPath, base+"_denoised"+FormatWAV.Extension()))
+		duration, err := denoiseFile(filepath.Join(memosPath, memo.Filename), dstPath, config)
+		if err != nil {
+			os.Remove(dstPath)
+			return editDoneMsg{action: "Noise reduction", err: err}
+		}
+		info, err := os.Stat(dstPath)
+		if err != nil {
+			return editDoneMsg{action: "Noise reduction", err: err}
+		}
+
+		return editDoneMsg{action: "Noise reduction", originals: []Memo{memo}, created: []Memo{{
+			ID:       newMemoID(),
+			Filename: filepath.Base(dstPath),
+			Name:     memo.Name + " (denoised)",
+			Duration: duration,
+			Created:  memo.Created,
+			Size:     info.Size(),
+			Tags:     append([]string{}, memo.Tags...),
+			Format:   FormatWAV.String(),
+		}}}
+	}
+}
+
+// Run the noise reduction chain over an audio file and write the result
+// to a WAV file. WAV sources keep their sample format; other files are
+// decoded and written as 16-bit. Returns the duration in seconds.
+func denoiseFile(srcPath, dstPath string, config Config) (float64, error) {
+	samples, sampleRate, channels, bits, err := readAudioFloat(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	if channels == 0 || sampleRate == 0 {
+		return 0, fmt.Errorf("no audio in %s", filepath.Base(srcPath))
+	}
+
+	reduceNoise(samples, channels, sampleRate, config)
+	if err := writeWAVFile(dstPath, samples, sampleRate, channels, bits); err != nil {
+		return 0, err
+	}
+	return float64(len(samples)/channels) / float64(sampleRate), nil
+}
+
+// Settings label for the mains hum filter
+func humLabel(freq float64) string {
+	if freq <= 0 {
+		return "Off"
+	}
+	return fmt.Sprintf("%g Hz", freq)
+}
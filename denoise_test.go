@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Root mean square of a stretch of samples
+func rms(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+// With a silent noise profile nothing is subtracted, so the windowed
+// analysis and synthesis give back the input
+func TestSpectralSubtractReconstructs(t *testing.T) {
+	const sampleRate = 16000
+	x := make([]float64, 3*sampleRate)
+	for i := sampleRate; i < len(x); i++ {
+		x[i] = 0.5 * math.Sin(2*math.Pi*440*float64(i)/sampleRate)
+	}
+	want := append([]float64(nil), x...)
+
+	spectralSubtract(x, sampleRate, 1)
+	for i := range x {
+		if math.Abs(x[i]-want[i]) > 1e-9 {
+			t.Fatalf("sample %d = %v, want %v", i, x[i], want[i])
+		}
+	}
+}
+
+// Steady noise heard in the profile is reduced while a tone over it is kept
+func TestSpectralSubtractReducesNoise(t *testing.T) {
+	const sampleRate = 16000
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]float64, 4*sampleRate)
+	for i := range noise {
+		noise[i] = 0.02 * rng.NormFloat64()
+	}
+	tone := make([]float64, len(noise))
+	for i := 2 * sampleRate; i < len(tone); i++ {
+		tone[i] = 0.3 * math.Sin(2*math.Pi*440*float64(i)/sampleRate)
+	}
+	x := make([]float64, len(noise))
+	for i := range x {
+		x[i] = noise[i] + tone[i]
+	}
+
+	spectralSubtract(x, sampleRate, 1)
+
+	// Noise alone, after the profile and the gain smoothing settle
+	if before, after := rms(noise[sampleRate:2*sampleRate]), rms(x[sampleRate:2*sampleRate]); after > before/3 {
+		t.Errorf("noise RMS %.4f reduced to %.4f, want below a third", before, after)
+	}
+
+	// The tone's level is kept within 1 dB
+	residual := make([]float64, sampleRate)
+	for i := range residual {
+		residual[i] = x[3*sampleRate+i] - tone[3*sampleRate+i]
+	}
+	toneLevel := rms(tone[3*sampleRate:])
+	if level := rms(x[3*sampleRate:]); math.Abs(20*math.Log10(level/toneLevel)) > 1 {
+		t.Errorf("tone RMS %.4f became %.4f", toneLevel, level)
+	}
+	if r := rms(residual); r > 0.02 {
+		t.Errorf("RMS difference from the clean tone = %.4f, want below the noise's 0.02", r)
+	}
+}
+
+func TestReduceNoiseHum(t *testing.T) {
+	const sampleRate = 16000
+	config := defaultConfig()
+	config.HumFrequency = 50
+	config.NoiseProfileTime = 0 // Filters only
+
+	hum := make([]float32, 2*sampleRate)
+	for i := range hum {
+		hum[i] = float32(0.3 * math.Sin(2*math.Pi*50*float64(i)/sampleRate))
+	}
+	reduceNoise(hum, 1, sampleRate, config)
+
+	settled := make([]float64, sampleRate)
+	for i := range settled {
+		settled[i] = float64(hum[sampleRate+i])
+	}
+	if level := rms(settled); level > 0.003 {
+		t.Errorf("50 Hz hum RMS after reduction = %.4f, want below 0.003", level)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// memoEdit is a trim, split or merge waiting for the user to decide
+// whether the original memos are deleted
+type memoEdit struct {
+	originals []Memo
+	created   []Memo
+}
+
+// Set the in point at the playhead. An out point before it is dropped.
+func (m *Model) markIn() {
+	memo, ok := m.editableMemo()
+	if !ok {
+		return
+	}
+	if m.selectionMemo != memo.Filename {
+		m.selection = timeRange{}
+	}
+	m.selectionMemo = memo.Filename
+	m.selection.start = m.audioDevice.playbackTime()
+	if m.selection.end <= m.selection.start {
+		m.selection.end = m.audioDevice.playbackLength()
+	}
+	m.showNotification(fmt.Sprintf("In point %s", formatDuration(m.selection.start)))
+}
+
+// Set the out point at the playhead. An in point after it is dropped.
+func (m *Model) markOut() {
+	memo, ok := m.editableMemo()
+	if !ok {
+		return
+	}
+	if m.selectionMemo != memo.Filename {
+		m.selection = timeRange{}
+	}
+	m.selectionMemo = memo.Filename
+	m.selection.end = m.audioDevice.playbackTime()
+	if m.selection.start >= m.selection.end {
+		m.selection.start = 0
+	}
+	m.showNotification(fmt.Sprintf("Out point %s", formatDuration(m.selection.end)))
+}
+
+// Forget the in and out points
+func (m *Model) clearMarks() {
+	m.selection = timeRange{}
+	m.selectionMemo = ""
+}
+
+// The memo loaded for playback, whose timeline the edit commands act on
+func (m Model) editableMemo() (Memo, bool) {
+	if !m.playing && !m.playbackPaused() {
+		return Memo{}, false
+	}
+	for _, memo := range m.memos {
+		if memo.Filename == m.audioDevice.playbackMemo {
+			return memo, true
+		}
+	}
+	return Memo{}, false
+}
+
+// editDoneMsg delivers the memos made by an edit run in the background,
+// or why it failed
+type editDoneMsg struct {
+	action    string // What was done, e.g. "Trim"
+	originals []Memo
+	created   []Memo
+	err       error
+}
+
+// Note that an edit is running in the background, unless one already is.
+// Edits read and write whole memos, so they run one at a time.
+func (m *Model) startEdit(description string) bool {
+	if m.editing != "" {
+		m.showNotification(fmt.Sprintf("%s, wait for it to finish", m.editing))
+		return false
+	}
+	m.editing = description
+	return true
+}
+
+// Offer the memos made by a finished edit, or report its failure
+func (m *Model) handleEditDone(msg editDoneMsg) {
+	m.editing = ""
+	if msg.err != nil {
+		log.Printf("%s failed: %v", msg.action, msg.err)
+		m.showNotification(fmt.Sprintf("%s failed: %v", msg.action, msg.err))
+		return
+	}
+	m.finishEdit(msg.originals, msg.created)
+}
+
+// Keep only the selected part of the playing memo, as a new memo
+func (m *Model) trimMemo() tea.Cmd {
+	memo, ok := m.editableMemo()
+	if !ok {
+		return nil
+	}
+	if m.selectionMemo != memo.Filename || m.selection.empty() {
+		m.showNotification("Mark in and out points to trim")
+		return nil
+	}
+	if !m.startEdit("Trimming") {
+		return nil
+	}
+
+	memosPath, span := m.config.MemosPath, m.selection
+	return func() tea.Msg {
+		trimmed, err := extractMemo(memosPath, memo, span, "trim", memo.Name+" (trimmed)")
+		if err != nil {
+			return editDoneMsg{action: "Trim", err: err}
+		}
+		return editDoneMsg{action: "Trim", originals: []Memo{memo}, created: []Memo{trimmed}}
+	}
+}
+
+// Split the playing memo at the playhead into two new memos
+func (m *Model) splitMemo() tea.Cmd {
+	memo, ok := m.editableMemo()
+	if !ok {
+		return nil
+	}
+	at, length := m.audioDevice.playbackTime(), m.audioDevice.playbackLength()
+	if at <= 0 || at >= length {
+		m.showNotification("Move the playhead inside the memo to split it")
+		return nil
+	}
+	if !m.startEdit("Splitting") {
+		return nil
+	}
+
+	memosPath := m.config.MemosPath
+	return func() tea.Msg {
+		var parts []Memo
+		for i, span := range []timeRange{{0, at}, {at, length}} {
+			part, err := extractMemo(memosPath, memo, span, fmt.Sprintf("part%d", i+1), fmt.Sprintf("%s (part %d)", memo.Name, i+1))
+			if err != nil {
+				for _, p := range parts {
+					os.Remove(filepath.Join(memosPath, p.Filename))
+				}
+				return editDoneMsg{action: "Split", err: err}
+			}
+			parts = append(parts, part)
+		}
+		return editDoneMsg{action: "Split", originals: []Memo{memo}, created: parts}
+	}
+}
+
+// Write a span of a memo to a new WAV file and describe it as a memo. The
+// new memo is dated by where the span starts in the original recording.
+func extractMemo(memosPath string, memo Memo, span timeRange, suffix, name string) (Memo, error) {
+	base := strings.TrimSuffix(memo.Filename, filepath.Ext(memo.Filename))
+	dstPath := uniquePath(filepath.Join(memosPath, fmt.Sprintf("%s_%s%s", base, suffix, FormatWAV.Extension())))
+
+	duration, err := writeWAVRange(filepath.Join(memosPath, memo.Filename), dstPath, span)
+	if err != nil {
+		os.Remove(dstPath)
+		return Memo{}, err
+	}
+	info, err := os.Stat(dstPath)
+	if err != nil {
+		return Memo{}, err
+	}
+
+	created := memo.Created.Add(span.start)
+	return Memo{
+		ID:       newMemoID(),
+		Filename: filepath.Base(dstPath),
+		Name:     name,
+		Duration: duration,
+		Created:  created,
+		Size:     info.Size(),
+		Tags:     append([]string{}, memo.Tags...),
+		Format:   FormatWAV.String(),
+	}, nil
+}
+
+// Add the memos created by an edit and ask whether to delete the originals
+func (m *Model) finishEdit(originals, created []Memo) {
+	m.memos = append(m.memos, created...)
+	sortMemos(m.memos)
+	m.memoList.SetItems(convertMemosToListItems(m.memos))
+	m.updateStore(created)
+	log.Printf("Created %d memo(s) from %d original(s)", len(created), len(originals))
+
+	m.clearMarks()
+	m.pendingEdit = &memoEdit{originals: originals, created: created}
+	m.promptPendingEdit()
+}
+
+// Ask whether to delete the originals of the pending edit once the memo
+// list is shown, so an edit finishing in the background doesn't cut into
+// renaming, the settings or a recording
+func (m *Model) promptPendingEdit() {
+	if m.pendingEdit == nil || (m.state != StateViewing && m.state != StatePlaying) {
+		return
+	}
+	if m.playing {
+		m.pausePlayback()
+	}
+	m.state = StateConfirmEdit
+}
+
+// Delete the originals of the pending edit, or keep them
+func (m *Model) confirmEdit(deleteOriginals bool) {
+	edit := m.pendingEdit
+	m.pendingEdit = nil
+	m.state = StateViewing
+	if edit == nil || !deleteOriginals {
+		return
+	}
+
+	if deleted := m.removeMemos(edit.originals); deleted == len(edit.originals) {
+		m.showNotification(fmt.Sprintf("Deleted %d original memo(s)", deleted))
+	}
+}
+
+// Write the frames of a span of an audio file to a new WAV file and return
+// its duration in seconds. PCM and float WAV data is copied unchanged;
+// other files are decoded and written as 16-bit.
+func writeWAVRange(srcPath, dstPath string, span timeRange) (float64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	if info, err := parseWAV(src); err == nil && info.BlockAlign == info.Channels*info.BitsPerSample/8 &&
+		(info.FormatTag == wavFormatIEEEFloat || info.BitsPerSample <= 24) {
+		first, count := spanFrames(span, info.SampleRate, info.DataSize/int64(info.BlockAlign))
+		size := count * int64(info.BlockAlign)
+		if err := writeWAVHeader(dst, info.SampleRate, info.Channels, info.BitsPerSample, size); err != nil {
+			return 0, err
+		}
+		if _, err := src.Seek(info.DataOffset+first*int64(info.BlockAlign), io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(dst, src, size); err != nil {
+			return 0, err
+		}
+		return float64(count) / float64(info.SampleRate), dst.Close()
+	}
+
+	samples, sampleRate, channels, err := readAudioData(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	first, count := spanFrames(span, sampleRate, int64(len(samples)/channels))
+	if err := writeWAVHeader(dst, sampleRate, channels, 16, count*int64(channels)*2); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 0, count*int64(channels)*2)
+	for _, s := range samples[first*int64(channels) : (first+count)*int64(channels)] {
+		buf = append(buf, byte(s), byte(uint16(s)>>8))
+	}
+	if _, err := dst.Write(buf); err != nil {
+		return 0, err
+	}
+	return float64(count) / float64(sampleRate), dst.Close()
+}
+
+// First frame and frame count of a span, clamped to the available frames
+func spanFrames(span timeRange, sampleRate int, frames int64) (int64, int64) {
+	first := int64(span.start.Seconds() * float64(sampleRate))
+	last := int64(span.end.Seconds() * float64(sampleRate))
+	first = max(0, first)
+	if first > frames {
+		first = frames
+	}
+	if last > frames {
+		last = frames
+	}
+	return first, max(0, last-first)
+}
+
+// A path that does not exist yet, numbering it if needed
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+}
+
+// Render the in and out points as markers under a timeline of the given
+// width, spanning the selection between them
+func renderTimelineMarks(selection timeRange, length time.Duration, width int) string {
+	column := func(t time.Duration) int {
+		return max(0, min(int(float64(t)/float64(length)*float64(width)), width-1))
+	}
+	in, out := column(selection.start), column(selection.end)
+
+	marks := []rune(strings.Repeat(" ", width+2))
+	for i := in + 1; i < out; i++ {
+		marks[i+1] = '─'
+	}
+	marks[in+1] = '└'
+	marks[out+1] = '┘'
+	if in == out {
+		marks[in+1] = '^'
+	}
+	return strings.TrimRight(string(marks), " ")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+)
+
+// In-place radix-2 complex FFT. len(re) must be a power of two and equal to
+// len(im). The inverse transform is not scaled by 1/n.
+func fft(re, im []float64, inverse bool) {
+	n := len(re)
+	if n < 2 {
+		return
+	}
+	shift := 64 - bits.TrailingZeros(uint(n))
+
+	// Bit-reversal permutation
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := sign * 2 * math.Pi / float64(size)
+		for k := 0; k < half; k++ {
+			wr, wi := math.Cos(step*float64(k)), math.Sin(step*float64(k))
+			for start := k; start < n; start += size {
+				j := start + half
+				tr := re[j]*wr - im[j]*wi
+				ti := re[j]*wi + im[j]*wr
+				re[j], im[j] = re[start]-tr, im[start]-ti
+				re[start] += tr
+				im[start] += ti
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFFTRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{2, 8, 256, 4096} {
+		re, im := make([]float64, n), make([]float64, n)
+		wantRe, wantIm := make([]float64, n), make([]float64, n)
+		for i := range re {
+			re[i], im[i] = rng.Float64()*2-1, rng.Float64()*2-1
+			wantRe[i], wantIm[i] = re[i], im[i]
+		}
+
+		fft(re, im, false)
+		fft(re, im, true)
+		for i := range re {
+			if math.Abs(re[i]/float64(n)-wantRe[i]) > 1e-12 || math.Abs(im[i]/float64(n)-wantIm[i]) > 1e-12 {
+				t.Fatalf("n = %d: sample %d = %v%+vi after round trip, want %v%+vi",
+					n, i, re[i]/float64(n), im[i]/float64(n), wantRe[i], wantIm[i])
+			}
+		}
+	}
+}
+
+// A cosine completing k cycles over the transform lands in bins k and n-k
+// with half its amplitude times n each
+func TestFFTCosine(t *testing.T) {
+	const n, k = 64, 5
+	re, im := make([]float64, n), make([]float64, n)
+	for i := range re {
+		re[i] = math.Cos(2 * math.Pi * k * float64(i) / n)
+	}
+
+	fft(re, im, false)
+	for bin := range re {
+		want := 0.0
+		if bin == k || bin == n-k {
+			want = n / 2
+		}
+		if math.Abs(re[bin]-want) > 1e-9 || math.Abs(im[bin]) > 1e-9 {
+			t.Errorf("bin %d = %v%+vi, want %v", bin, re[bin], im[bin], want)
+		}
+	}
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/help"
@@ -19,7 +22,6 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/gordonklaus/portaudio"
 )
 
 // Application constants
@@ -28,13 +30,15 @@ const (
 	ConfigDir    = ".voicelog"
 	MemosDir     = "memos"
 	ConfigFile   = "config.json"
-	MetadataFile = "metadata.json"
+	MetadataFile = "metadata.json" // Memo metadata of earlier versions, imported into StoreFile
+	StoreFile    = "memos.db"
+	PeaksFile    = "peaks.json"
 	LogFile      = "voicelog.log"
 
 	// Audio settings
 	SampleRate   = 44100
 	ChannelCount = 2
-	BitDepth     = 2 // 16-bit
+	BitDepth     = 16 // Bits per sample: 16, 24 or 32 (float)
 )
 
 // Setup logging
@@ -67,6 +71,9 @@ const (
 	StateRenaming
 	StateTagging
 	StateSettings
+	StateRecovering
+	StateConfirmEdit
+	StateSearching
 )
 
 // Audio formats
@@ -104,20 +111,35 @@ func (f AudioFormat) Extension() string {
 	}
 }
 
+// Look up the format of a file extension such as ".wav"
+func formatForExtension(ext string) (AudioFormat, bool) {
+	for _, format := range []AudioFormat{FormatWAV, FormatMP3, FormatOGG} {
+		if strings.EqualFold(ext, format.Extension()) {
+			return format, true
+		}
+	}
+	return FormatWAV, false
+}
+
 // Memo represents a voice memo with metadata
 type Memo struct {
 	ID       string    `json:"id"`
 	Filename string    `json:"filename"`
-	Name     string    `json:"title"` // Changed from Title to Name to avoid conflict
+	Name     string    `json:"name"` // Stored as "title" before schema version 1
 	Duration float64   `json:"duration"`
 	Created  time.Time `json:"created"`
 	Size     int64     `json:"size"`
 	Tags     []string  `json:"tags"`
 	Format   string    `json:"format"`
+
+	Selected bool `json:"-"` // Selected in the list for merging, not saved
 }
 
 // Implement list.Item interface
 func (m Memo) Title() string {
+	if m.Selected {
+		return "✓ " + truncateText(m.Name, 28)
+	}
 	return truncateText(m.Name, 30) // Limit title to 30 characters
 }
 
@@ -191,6 +213,53 @@ type Config struct {
 	ChannelCount  int               `json:"channel_count"`
 	Volume        float64           `json:"volume"`
 	AudioDevices  []AudioDeviceInfo `json:"audio_devices"`
+	MP3Bitrate    int               `json:"mp3_bitrate"` // kbps
+	OggBitrate    int               `json:"ogg_bitrate"` // kbps
+
+	// Audio backend ("portaudio", "file" or "null") and the WAV files
+	// used by the file backend in place of a microphone and speakers
+	AudioBackend      string `json:"audio_backend"`
+	BackendInputFile  string `json:"backend_input_file,omitempty"`
+	BackendOutputFile string `json:"backend_output_file,omitempty"`
+
+	// Voice-activated recording: input is only written while its peak is
+	// above VoiceThreshold and for VoiceHangover after. A recording stops
+	// after SilenceTimeout without voice; 0 keeps it going.
+	VoiceActivated bool    `json:"voice_activated"`
+	VoiceThreshold float64 `json:"voice_threshold"` // dBFS
+	VoiceHangover  float64 `json:"voice_hangover"`  // seconds
+	SilenceTimeout float64 `json:"silence_timeout"` // seconds
+
+	// Post-processing of finished recordings: trim silence below
+	// TrimThreshold from both ends and normalize to TargetLoudness. With
+	// KeepOriginal the unprocessed recording is kept as a separate memo.
+	TrimSilence    bool    `json:"trim_silence"`
+	TrimThreshold  float64 `json:"trim_threshold"` // dBFS
+	Normalize      bool    `json:"normalize"`
+	TargetLoudness float64 `json:"target_loudness"` // LUFS
+	KeepOriginal   bool    `json:"keep_original"`
+
+	// Noise reduction, applied to finished recordings before the other
+	// post-processing: a high-pass at HighPassFrequency, notches at the
+	// HumFrequency mains hum (0 for none) and its harmonics, and spectral
+	// subtraction of the noise in the first NoiseProfileTime seconds
+	NoiseReduction    bool    `json:"noise_reduction"`
+	HighPassFrequency float64 `json:"high_pass_frequency"` // Hz
+	HumFrequency      float64 `json:"hum_frequency"`       // Hz
+	NoiseProfileTime  float64 `json:"noise_profile_time"`  // seconds
+
+	// Seconds of input kept in memory while the memo list is shown and
+	// put at the start of the next recording; 0 turns the microphone off
+	// outside recordings
+	PreRollTime float64 `json:"pre_roll_time"`
+
+	// Speech-to-text engine run on new recordings: "whisper" runs the
+	// whisper.cpp binary at WhisperPath with the model at WhisperModel,
+	// "fake" makes placeholder transcripts and empty turns it off
+	Transcriber     string `json:"transcriber,omitempty"`
+	WhisperPath     string `json:"whisper_path,omitempty"`
+	WhisperModel    string `json:"whisper_model,omitempty"`
+	WhisperLanguage string `json:"whisper_language,omitempty"` // e.g. "en", or "auto" to detect
 }
 
 // Keybindings holds custom key configurations
@@ -206,65 +275,19 @@ type Keybindings struct {
 	Quit   string `json:"quit"`
 }
 
-// Detect available audio devices using PortAudio
-func detectAudioDevices() []AudioDeviceInfo {
-	var devices []AudioDeviceInfo
-
-	// Initialize PortAudio
-	if err := portaudio.Initialize(); err != nil {
-		// Fallback if initialization fails
-		return append(devices, AudioDeviceInfo{
-			ID:        "default",
-			Name:      "Default Device (Fallback)",
-			IsDefault: true,
-			IsInput:   true,
-			IsOutput:  true,
-		})
-	}
-	defer func() {
-		if err := portaudio.Terminate(); err != nil {
-			log.Printf("Error terminating PortAudio: %v", err)
-		}
-	}()
-
-	// Get host APIs (e.g., ALSA on Linux, CoreAudio on macOS)
-	hostApis, err := portaudio.HostApis()
+// Detect available audio devices using the configured backend
+func detectAudioDevices(backend AudioBackend) []AudioDeviceInfo {
+	devices, err := backend.Devices()
 	if err != nil {
-		return append(devices, AudioDeviceInfo{
+		log.Printf("Error detecting audio devices (%s): %v", backend.Name(), err)
+		// Fallback if the backend cannot enumerate devices
+		return []AudioDeviceInfo{{
 			ID:        "default",
-			Name:      "Default Device (Error)",
+			Name:      "Default Device (Fallback)",
 			IsDefault: true,
 			IsInput:   true,
 			IsOutput:  true,
-		})
-	}
-
-	// Get default devices
-	defaultInput, _ := portaudio.DefaultInputDevice()
-	defaultOutput, _ := portaudio.DefaultOutputDevice()
-
-	// Enumerate devices from all host APIs
-	for _, host := range hostApis {
-		log.Printf("Host API: %s", host.Name)
-		for _, dev := range host.Devices {
-			// Skip devices with no I/O channels
-			if dev.MaxInputChannels == 0 && dev.MaxOutputChannels == 0 {
-				continue
-			}
-
-			// Create device info
-			info := AudioDeviceInfo{
-				ID:   fmt.Sprintf("%d", dev.Index), // Unique ID based on PortAudio index
-				Name: fmt.Sprintf("%s (%s)", dev.Name, host.Name),
-				IsDefault: (defaultInput != nil && dev.Index == defaultInput.Index) ||
-					(defaultOutput != nil && dev.Index == defaultOutput.Index),
-				IsInput:  dev.MaxInputChannels > 0,
-				IsOutput: dev.MaxOutputChannels > 0,
-			}
-			log.Printf("Found device: ID=%s, Name=%s, Input=%v, Output=%v, Channels=%d",
-				info.ID, info.Name, info.IsInput, info.IsOutput, dev.MaxInputChannels)
-			devices = append(devices, info)
-		}
+		}}
 	}
 
 	// If no devices found, add a fallback
@@ -308,25 +331,6 @@ func setDefaultDevices(config *Config) {
 		config.InputDevice, config.OutputDevice)
 }
 
-// Get device by ID from PortAudio
-func getDeviceByID(deviceID string) *portaudio.DeviceInfo {
-	devices, err := portaudio.Devices()
-	if err != nil {
-		return nil
-	}
-
-	deviceIdx, err := strconv.Atoi(deviceID)
-	if err != nil {
-		return nil
-	}
-
-	if deviceIdx >= 0 && deviceIdx < len(devices) {
-		return devices[deviceIdx]
-	}
-
-	return nil
-}
-
 // Default configuration
 func defaultConfig() Config {
 	homeDir, _ := os.UserHomeDir()
@@ -340,6 +344,22 @@ func defaultConfig() Config {
 		BitDepth:      BitDepth,
 		ChannelCount:  ChannelCount,
 		Volume:        1.0, // Default volume (100%)
+		MP3Bitrate:    DefaultMP3Bitrate,
+		OggBitrate:    DefaultOggBitrate,
+		AudioBackend:  BackendPortAudio,
+
+		// Voice activation
+		VoiceThreshold: DefaultVoiceThreshold,
+		VoiceHangover:  DefaultVoiceHangover,
+
+		// Post-processing
+		TrimThreshold:  DefaultTrimThreshold,
+		TargetLoudness: DefaultTargetLoudness,
+
+		// Noise reduction
+		HighPassFrequency: DefaultHighPassFrequency,
+		NoiseProfileTime:  DefaultNoiseProfileTime,
+
 		Keybindings: Keybindings{
 			Record: " ", // spacebar
 			Play:   "enter",
@@ -357,22 +377,46 @@ func defaultConfig() Config {
 
 // Audio device and context
 type AudioDevice struct {
-	stream        *portaudio.Stream // PortAudio stream for recording/playback
-	recordingFile *os.File          // File for recording audio data
-	playbackData  []int16           // Audio data for playback
-	playbackPos   int               // Current position in playback data
-}
-
-// Waveform data for visualization
+	stream        AudioStream    // Backend stream for recording/playback
+	recordingFile *os.File       // File for recording audio data
+	encodePath    string         // Final path when the recording is encoded after capture
+	headerSynced  time.Time      // Last time the recording's WAV header was updated
+	captureFormat wavInfo        // Rate, channels and sample size of the recording file
+	resampler     *resampler     // Converts input from the device rate to captureFormat's
+	resampleBuf   []float32      // Reused buffer for resampled input
+	captureBuf    []byte         // Reused buffer for encoding input samples
+	playbackData  []int16        // Audio data for playback
+	playbackPos   atomic.Int64   // Current sample position in playback data
+	playbackMemo  string         // Filename of the memo loaded for playback
+	playbackRate  int            // Output stream rate of playbackData
+	playbackChans int            // Output stream channels of playbackData
+	playbackSpeed atomic.Uint64  // Playback speed as float64 bits, read by the output callback
+	stretcher     *timeStretcher // Time stretcher for speeds other than 1x
+
+	// Capture pipeline: the input callback feeds the rings, a writer
+	// goroutine drains captureRing to disk and a metering goroutine turns
+	// meterRing into level messages
+	captureRing   *ringBuffer
+	meterRing     *ringBuffer
+	levels        chan audioLevelMsg
+	stopCapture   chan struct{}
+	captureWG     sync.WaitGroup
+	capturePaused atomic.Bool // Input is metered but not written while set
+	voiceGate     *voiceGate  // Decides what to write in voice-activated mode
+
+	// Pre-roll the recording took over its stream from, written first
+	preRoll *preRoll
+}
+
+// Waveform data for visualization: the envelope of recent input, oldest first
 type WaveformData struct {
-	samples []float32
-	max     float32
+	slices []envelopeSlice
 }
 
 // VU meter data
 type VUMeterData struct {
-	leftLevel  float32
-	rightLevel float32
+	left  meterChannel
+	right meterChannel
 }
 
 // Model represents the application state
@@ -383,16 +427,55 @@ type Model struct {
 	memos       []Memo
 	selectedIdx int
 
+	// Where memo metadata is kept
+	store MemoStore
+
+	// Files found at startup without metadata, offered for import
+	orphanedMemos []Memo
+
 	// Audio
-	audioDevice   *AudioDevice
-	recording     bool
-	playing       bool
-	recordingTime time.Duration
-	playbackPos   time.Duration
+	backend          AudioBackend
+	audioDevice      *AudioDevice
+	recording        bool
+	recordingPaused  bool
+	savingRecordings int  // Stopped recordings still being processed and encoded
+	quitting         bool // Quit once the recordings and edit in progress are saved
+	playing          bool
+	recordingTime    time.Duration
+	playbackPos      time.Duration
+	playbackSpeed    float64
 
 	// Visualization data
-	waveform WaveformData
-	vuMeter  VUMeterData
+	waveform        WaveformData
+	vuMeter         VUMeterData
+	peakCache       map[string]memoPeaks // Overview peaks by memo filename
+	overviewPending map[string]bool      // Overviews being computed
+	selection       timeRange            // Region marked between in and out points
+	selectionMemo   string               // Filename of the memo the selection belongs to
+
+	// Trim or split running in the background, e.g. "Trimming", and one
+	// waiting for confirmation to delete the original
+	editing     string
+	pendingEdit *memoEdit
+
+	// Input kept while the memo list is shown, for the start of a recording
+	preRoll       *preRoll
+	preRollFailed bool
+
+	// Speech-to-text, one memo at a time in the background
+	transcriber      Transcriber
+	transcriberCtx   context.Context
+	stopTranscriber  context.CancelFunc
+	transcripts      map[string]*Transcript // Loaded transcripts by memo filename, nil if none
+	transcribeQueue  []string               // Memo filenames waiting for transcription
+	transcribing     string                 // Memo filename being transcribed
+	transcriptErrors map[string]string      // Why the last transcription of a memo failed
+
+	// Search mode: the index built on entering it and the results of the
+	// query typed so far
+	searchIndex    *searchIndex
+	searchResults  []searchResult
+	searchSelected int
 
 	// UI components
 	textInput textinput.Model
@@ -435,19 +518,57 @@ type keyMap struct {
 	Escape   key.Binding
 	Left     key.Binding
 	Right    key.Binding
+	Yes      key.Binding
+	No       key.Binding
+
+	// Playback seeking
+	SeekBack        key.Binding
+	SeekForward     key.Binding
+	SeekBackLong    key.Binding
+	SeekForwardLong key.Binding
+	JumpTo          key.Binding
+	SpeedUp         key.Binding
+	SpeedDown       key.Binding
+
+	// Editing
+	MarkIn     key.Binding
+	MarkOut    key.Binding
+	ClearMarks key.Binding
+	Trim       key.Binding
+	Split      key.Binding
+
+	// Merging
+	ToggleSelect key.Binding
+	Merge        key.Binding
+
+	// Recording
+	PauseRecording key.Binding
+
+	// Processing
+	Denoise    key.Binding
+	Transcribe key.Binding
+
+	// Search
+	Search     key.Binding
+	PrevResult key.Binding
+	NextResult key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Record, k.Play, k.Export, k.Help, k.Quit}
+	return []key.Binding{k.Record, k.Play, k.Search, k.Export, k.Help, k.Quit}
 }
 
 // FullHelp returns keybindings for the expanded help view
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Record, k.Play, k.Stop, k.Up, k.Down}, // Core controls
-		{k.Rename, k.Tag, k.Delete, k.Export},    // Management
-		{k.Settings, k.TestFile, k.Help, k.Quit}, // Other
+		{k.Record, k.PauseRecording, k.Play, k.Stop, k.Up, k.Down},               // Core controls
+		{k.SeekBack, k.SeekForward, k.SeekBackLong, k.SeekForwardLong, k.JumpTo}, // Seeking
+		{k.SpeedDown, k.SpeedUp},                                       // Speed
+		{k.MarkIn, k.MarkOut, k.ClearMarks, k.Trim, k.Split},           // Editing
+		{k.Denoise, k.Transcribe},                                      // Processing
+		{k.Rename, k.Tag, k.Delete, k.Export, k.ToggleSelect, k.Merge}, // Management
+		{k.Search, k.Settings, k.TestFile, k.Help, k.Quit},             // Other
 	}
 }
 
@@ -464,6 +585,10 @@ var keys = keyMap{
 		key.WithKeys("ctrl+x"),
 		key.WithHelp("ctrl+x", "stop"),
 	),
+	PauseRecording: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pause recording"),
+	),
 	Delete: key.NewBinding(
 		key.WithKeys("ctrl+d"),
 		key.WithHelp("ctrl+d", "delete"),
@@ -515,6 +640,90 @@ var keys = keyMap{
 	Enter: key.NewBinding(
 		key.WithKeys("enter"),
 	),
+	Yes: key.NewBinding(
+		key.WithKeys("y", "Y"),
+		key.WithHelp("y", "yes"),
+	),
+	No: key.NewBinding(
+		key.WithKeys("n", "N"),
+		key.WithHelp("n", "no"),
+	),
+	SeekBack: key.NewBinding(
+		key.WithKeys("left"),
+		key.WithHelp("←", "back 5s"),
+	),
+	SeekForward: key.NewBinding(
+		key.WithKeys("right"),
+		key.WithHelp("→", "forward 5s"),
+	),
+	SeekBackLong: key.NewBinding(
+		key.WithKeys("shift+left", "["),
+		key.WithHelp("[", "back 30s"),
+	),
+	SeekForwardLong: key.NewBinding(
+		key.WithKeys("shift+right", "]"),
+		key.WithHelp("]", "forward 30s"),
+	),
+	JumpTo: key.NewBinding(
+		key.WithKeys("0", "1", "2", "3", "4", "5", "6", "7", "8", "9"),
+		key.WithHelp("0-9", "jump to 0-90%"),
+	),
+	SpeedUp: key.NewBinding(
+		key.WithKeys("+", "="),
+		key.WithHelp("+", "faster"),
+	),
+	SpeedDown: key.NewBinding(
+		key.WithKeys("-", "_"),
+		key.WithHelp("-", "slower"),
+	),
+	MarkIn: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "mark in"),
+	),
+	MarkOut: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "mark out"),
+	),
+	ClearMarks: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "clear marks"),
+	),
+	Trim: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "trim to marks"),
+	),
+	Split: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "split at playhead"),
+	),
+	ToggleSelect: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "select"),
+	),
+	Merge: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "merge selected"),
+	),
+	Denoise: key.NewBinding(
+		key.WithKeys("ctrl+n"),
+		key.WithHelp("ctrl+n", "reduce noise"),
+	),
+	Transcribe: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "transcribe"),
+	),
+	Search: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "search"),
+	),
+	PrevResult: key.NewBinding(
+		key.WithKeys("up", "ctrl+p"),
+		key.WithHelp("↑", "previous result"),
+	),
+	NextResult: key.NewBinding(
+		key.WithKeys("down", "ctrl+n"),
+		key.WithHelp("↓", "next result"),
+	),
 	Escape: key.NewBinding(
 		key.WithKeys("esc"),
 	),
@@ -571,6 +780,21 @@ var (
 	vuMeterStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color(PrimaryGreen))
 
+	vuMeterWarnStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color(AccentOrange))
+
+	vuMeterHotStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color(AccentPink))
+
+	clipStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color(TextPrimary)).
+			Background(lipgloss.Color(AccentPink)).
+			Bold(true)
+
+	overviewPlayheadStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color(TextPrimary)).
+				Background(lipgloss.Color(AccentPink))
+
 	statusBarStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color(TextPrimary)).
 			Background(lipgloss.Color(PrimaryBlue)).
@@ -592,8 +816,10 @@ var (
 				Padding(1, 2)
 )
 
-// Initialize the application
-func initialModel() Model {
+// Initialize the application. Fails if the memo store can't be opened,
+// e.g. because it was written by a newer voicelog (StoreVersionError) or
+// another instance holds it (ErrStoreLocked).
+func initialModel() (Model, error) {
 	config := loadConfig()
 
 	// Create directories if they don't exist
@@ -612,8 +838,16 @@ func initialModel() Model {
 	h := help.New()
 	h.Width = 80
 
-	// Load existing memos
-	memos := loadMemos(config.MemosPath)
+	// Load existing memos and look for recordings interrupted by a crash
+	store, err := openMemoStore(config.MemosPath)
+	if err != nil {
+		return Model{}, err
+	}
+	memos, orphans := loadMemos(store, config.MemosPath)
+	state := StateViewing
+	if len(orphans) > 0 {
+		state = StateRecovering
+	}
 
 	// Initialize memo list
 	memoList := list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0)
@@ -624,10 +858,16 @@ func initialModel() Model {
 	memoList.SetFilteringEnabled(false) // Disable filtering
 	memoList.SetItems(convertMemosToListItems(memos))
 
+	// Cancelled on exit to stop a transcription engine still running
+	transcriberCtx, stopTranscriber := context.WithCancel(context.Background())
+
 	return Model{
-		state:               StateViewing,
+		state:               state,
 		config:              config,
+		backend:             newAudioBackend(config),
+		store:               store,
 		memos:               memos,
+		orphanedMemos:       orphans,
 		selectedIdx:         0,
 		settingsSelectedIdx: 0,
 		availableDevices:    config.AudioDevices, // This will be empty initially
@@ -635,7 +875,15 @@ func initialModel() Model {
 		help:                h,
 		memoList:            memoList,
 		lastUpdate:          time.Now(),
-	}
+		playbackSpeed:       1,
+		peakCache:           loadPeakCache(config.MemosPath),
+		overviewPending:     make(map[string]bool),
+		transcriber:         newTranscriber(config),
+		transcriberCtx:      transcriberCtx,
+		stopTranscriber:     stopTranscriber,
+		transcripts:         make(map[string]*Transcript),
+		transcriptErrors:    make(map[string]string),
+	}, nil
 }
 
 // Convert memos to list items
@@ -679,82 +927,60 @@ func loadConfig() Config {
 	if config.ChannelCount <= 0 {
 		config.ChannelCount = ChannelCount
 	}
-	if config.BitDepth <= 0 {
+	switch config.BitDepth {
+	case 16, 24, 32:
+	case 2, 3, 4:
+		// Older configs stored the depth in bytes
+		config.BitDepth *= 8
+	default:
 		config.BitDepth = BitDepth
 	}
 	if config.Volume <= 0.0 || config.Volume > 1.0 {
 		config.Volume = 1.0
 	}
-
-	return config
-}
-
-// Read WAV file data
-func readWAVData(filePath string) ([]int16, int, int, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, 0, 0, err
+	if config.MP3Bitrate <= 0 {
+		config.MP3Bitrate = DefaultMP3Bitrate
+	} else if valid := nearestMP3Bitrate(config.SampleRate, config.MP3Bitrate); valid != config.MP3Bitrate {
+		log.Printf("MP3 bitrate %d kbps is not valid at %d Hz, using %d kbps", config.MP3Bitrate, config.SampleRate, valid)
+		config.MP3Bitrate = valid
 	}
-	defer file.Close()
-
-	// Read WAV header
-	header := make([]byte, 44)
-	if _, err := file.Read(header); err != nil {
-		return nil, 0, 0, err
+	if config.OggBitrate < MinOggBitrate || config.OggBitrate > MaxOggBitrate {
+		config.OggBitrate = DefaultOggBitrate
 	}
-
-	// Parse header
-	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
-		return nil, 0, 0, fmt.Errorf("not a valid WAV file")
+	if config.AudioBackend == "" {
+		config.AudioBackend = BackendPortAudio
 	}
-
-	// Get format info
-	channels := int(binary.LittleEndian.Uint16(header[22:24]))
-	sampleRate := int(binary.LittleEndian.Uint32(header[24:28]))
-	_ = int(binary.LittleEndian.Uint16(header[34:36])) // bitsPerSample - not used but parsed for completeness
-
-	// Read audio data
-	dataSize := int64(binary.LittleEndian.Uint32(header[40:44]))
-	audioData := make([]byte, dataSize)
-	if _, err := file.Read(audioData); err != nil {
-		return nil, 0, 0, err
+	if config.VoiceThreshold < MinVoiceThreshold || config.VoiceThreshold > MaxVoiceThreshold {
+		config.VoiceThreshold = DefaultVoiceThreshold
 	}
-
-	// Convert to int16 samples
-	samples := make([]int16, len(audioData)/2)
-	for i := 0; i < len(samples); i++ {
-		samples[i] = int16(binary.LittleEndian.Uint16(audioData[i*2 : i*2+2]))
+	if config.VoiceHangover < MinVoiceHangover || config.VoiceHangover > MaxVoiceHangover {
+		config.VoiceHangover = DefaultVoiceHangover
+	}
+	if config.SilenceTimeout < 0 {
+		config.SilenceTimeout = 0
+	}
+	if config.TrimThreshold >= 0 || config.TrimThreshold < vuFloorDB {
+		config.TrimThreshold = DefaultTrimThreshold
+	}
+	if config.TargetLoudness >= 0 || config.TargetLoudness < loudnessAbsoluteGate {
+		config.TargetLoudness = DefaultTargetLoudness
+	}
+	if config.HighPassFrequency <= 0 || config.HighPassFrequency > float64(config.SampleRate)/2 {
+		config.HighPassFrequency = DefaultHighPassFrequency
+	}
+	if config.HumFrequency < 0 {
+		config.HumFrequency = 0
+	}
+	if config.NoiseProfileTime <= 0 {
+		config.NoiseProfileTime = DefaultNoiseProfileTime
+	}
+	if config.PreRollTime < 0 {
+		config.PreRollTime = 0
+	} else if config.PreRollTime > MaxPreRollTime {
+		config.PreRollTime = MaxPreRollTime
 	}
 
-	return samples, sampleRate, channels, nil
-}
-
-// Write WAV file header
-func writeWAVHeader(file *os.File, sampleRate, channels, bitsPerSample int, dataSize int64) error {
-	// WAV header structure
-	header := make([]byte, 44)
-
-	// RIFF header
-	copy(header[0:4], "RIFF")
-	binary.LittleEndian.PutUint32(header[4:8], uint32(36+dataSize))
-	copy(header[8:12], "WAVE")
-
-	// fmt chunk
-	copy(header[12:16], "fmt ")
-	binary.LittleEndian.PutUint32(header[16:20], 16) // fmt chunk size
-	binary.LittleEndian.PutUint16(header[20:22], 1)  // audio format (PCM)
-	binary.LittleEndian.PutUint16(header[22:24], uint16(channels))
-	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
-	binary.LittleEndian.PutUint32(header[28:32], uint32(sampleRate*channels*bitsPerSample/8))
-	binary.LittleEndian.PutUint16(header[32:34], uint16(channels*bitsPerSample/8))
-	binary.LittleEndian.PutUint16(header[34:36], uint16(bitsPerSample))
-
-	// data chunk
-	copy(header[36:40], "data")
-	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
-
-	_, err := file.Write(header)
-	return err
+	return config
 }
 
 // Save configuration to file
@@ -774,16 +1000,18 @@ func saveConfig(config Config) error {
 	return os.WriteFile(configPath, data, 0644)
 }
 
-// Load memos from directory
-func loadMemos(memosPath string) []Memo {
-	var memos []Memo
+// Load memos from the store, together with any orphaned audio files found
+// in the memos directory
+func loadMemos(store MemoStore, memosPath string) ([]Memo, []Memo) {
+	memos := storedMemos(store, memosPath)
+	return memos, findOrphanedMemos(memosPath, memos)
+}
 
-	// Load metadata
-	metadataPath := filepath.Join(memosPath, MetadataFile)
-	if data, err := os.ReadFile(metadataPath); err == nil {
-		if err := json.Unmarshal(data, &memos); err != nil {
-			log.Printf("Error unmarshaling metadata: %v", err)
-		}
+// The memos in the store whose audio files still exist, newest first
+func storedMemos(store MemoStore, memosPath string) []Memo {
+	memos, err := store.Memos()
+	if err != nil {
+		log.Printf("Error loading memos metadata: %v", err)
 	}
 
 	// Verify files still exist and update info
@@ -796,22 +1024,15 @@ func loadMemos(memosPath string) []Memo {
 		}
 	}
 
-	// Sort by creation date (newest first)
-	sort.Slice(validMemos, func(i, j int) bool {
-		return validMemos[i].Created.After(validMemos[j].Created)
-	})
-
+	sortMemos(validMemos)
 	return validMemos
 }
 
-// Save memos metadata
-func saveMemos(memos []Memo, memosPath string) error {
-	metadataPath := filepath.Join(memosPath, MetadataFile)
-	data, err := json.MarshalIndent(memos, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(metadataPath, data, 0644)
+// Sort by creation date (newest first)
+func sortMemos(memos []Memo) {
+	sort.Slice(memos, func(i, j int) bool {
+		return memos[i].Created.After(memos[j].Created)
+	})
 }
 
 // Generate filename for new memo
@@ -820,6 +1041,22 @@ func generateFilename(format AudioFormat) string {
 	return fmt.Sprintf("memo_%s%s", timestamp, format.Extension())
 }
 
+// Last memo ID handed out by newMemoID, in nanoseconds
+var lastMemoID atomic.Int64
+
+// Generate the ID of a new memo: the current time in nanoseconds, moved
+// past the last ID generated so memos made in the same instant still get
+// IDs of their own
+func newMemoID() string {
+	for {
+		last := lastMemoID.Load()
+		id := max(time.Now().UnixNano(), last+1)
+		if lastMemoID.CompareAndSwap(last, id) {
+			return fmt.Sprintf("%d", id)
+		}
+	}
+}
+
 // Format bytes to human readable
 func formatBytes(bytes int64) string {
 	const unit = 1024
@@ -851,7 +1088,6 @@ func min(a, b int) int {
 
 // Tea program messages
 type tickMsg time.Time
-type recordingTickMsg time.Time
 
 // Tick command for animations
 func tick() tea.Cmd {
@@ -860,13 +1096,6 @@ func tick() tea.Cmd {
 	})
 }
 
-// Recording tick command
-func recordingTick() tea.Cmd {
-	return tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
-		return recordingTickMsg(t)
-	})
-}
-
 // Initialize the program
 func (m Model) Init() tea.Cmd {
 	return tick()
@@ -889,26 +1118,35 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleTextInput(msg)
 		case StateSettings:
 			return m.handleSettingsKeys(msg)
+		case StateRecovering:
+			return m.handleRecoveryKeys(msg)
+		case StateConfirmEdit:
+			return m.handleConfirmEditKeys(msg)
+		case StateSearching:
+			return m.handleSearchKeys(msg)
 		default:
 			return m.handleMainKeys(msg)
 		}
 
+	case tea.MouseMsg:
+		m.handleMouse(msg)
+
 	case tickMsg:
 		now := time.Now()
-		if m.recording {
+		if m.recording && !m.recordingPaused && !m.voiceGateClosed() {
 			m.recordingTime = now.Sub(m.lastUpdate) + m.recordingTime
 			m.recordingPulse = (m.recordingPulse + 1) % 20
 		}
+		cmds = append(cmds, m.checkSilenceTimeout())
+		m.updatePreRoll()
 		if m.playing {
 			// Update playback position based on real audio data
 			if m.audioDevice != nil && m.audioDevice.playbackData != nil {
-				// Calculate position based on samples played
-				samplesPerSecond := 44100 // Default sample rate
-				// Estimate position based on playback position in samples
-				m.playbackPos = time.Duration(float64(m.audioDevice.playbackPos) / float64(samplesPerSecond) * float64(time.Second))
+				// Calculate position based on frames played at the output rate
+				m.playbackPos = m.audioDevice.playbackTime()
 
 				// Check if we've reached the end of the audio data
-				if m.audioDevice.playbackPos >= len(m.audioDevice.playbackData) {
+				if m.audioDevice.playbackPos.Load() >= int64(len(m.audioDevice.playbackData)) {
 					log.Printf("Auto-stopping playback - reached end of audio data")
 					m.stopPlayback()
 				}
@@ -921,13 +1159,33 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		m.lastUpdate = now
-		cmds = append(cmds, tick())
-
-	case recordingTickMsg:
-		if m.recording {
-			// Update waveform data (simulated)
-			m.updateWaveform()
-			cmds = append(cmds, recordingTick())
+		m.promptPendingEdit()
+		m.loadSelectedTranscript()
+		cmds = append(cmds, tick(), m.requestOverview(), m.requestTranscription())
+
+	case overviewMsg:
+		m.handleOverview(msg)
+
+	case transcriptMsg:
+		m.handleTranscript(msg)
+
+	case recordingSavedMsg:
+		m.handleRecordingSaved(msg)
+		cmds = append(cmds, m.quitWhenSaved())
+
+	case editDoneMsg:
+		m.handleEditDone(msg)
+		cmds = append(cmds, m.quitWhenSaved())
+
+	case audioLevelMsg:
+		if m.recording && m.audioDevice != nil {
+			// The meters stay live while paused or waiting for voice, but
+			// the waveform only shows what is recorded
+			if !m.recordingPaused && !m.voiceGateClosed() {
+				m.waveform.append(msg.envelope)
+			}
+			m.vuMeter = msg.vuMeter
+			cmds = append(cmds, listenForLevels(m.audioDevice.levels))
 		}
 	}
 
@@ -939,6 +1197,7 @@ func (m Model) handleSettingsKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, keys.Escape), key.Matches(msg, keys.Quit):
 		m.state = StateViewing
+		m.preRollFailed = false
 		if err := saveConfig(m.config); err != nil {
 			log.Printf("Error saving config: %v", err)
 		}
@@ -949,7 +1208,7 @@ func (m Model) handleSettingsKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 	case key.Matches(msg, keys.Down):
-		if m.settingsSelectedIdx < 6 { // 7 settings items (0-6)
+		if m.settingsSelectedIdx < 17 { // 18 settings items (0-17)
 			m.settingsSelectedIdx++
 		}
 
@@ -1019,10 +1278,8 @@ func (m *Model) loadTestFile() {
 
 	m.selectedIdx = 0 // Select the test file
 
-	// Save the updated memos to metadata
-	if err := saveMemos(m.memos, m.config.MemosPath); err != nil {
-		log.Printf("Error saving memos metadata: %v", err)
-	}
+	// Save the test memo to metadata
+	m.updateStore([]Memo{testMemo})
 
 	log.Printf("Test file loaded: %s", testFilename)
 }
@@ -1107,7 +1364,9 @@ func (m *Model) adjustSetting(delta int) {
 			nextIdx := (currentIdx + delta + len(formats)) % len(formats)
 			m.config.DefaultFormat = formats[nextIdx]
 		}
-	case 6: // Volume
+	case 6: // Bitrate of the compressed formats
+		m.config.adjustBitrate(delta)
+	case 7: // Volume
 		currentVolume := m.getPlayerVolume()
 		newVolume := currentVolume + float64(delta)*0.1
 		if newVolume < 0.0 {
@@ -1116,6 +1375,59 @@ func (m *Model) adjustSetting(delta int) {
 			newVolume = 1.0
 		}
 		m.setPlayerVolume(newVolume)
+	case 8: // Voice Activation
+		m.config.VoiceActivated = !m.config.VoiceActivated
+	case 9: // Voice Threshold
+		m.config.VoiceThreshold = math.Max(MinVoiceThreshold, math.Min(m.config.VoiceThreshold+float64(delta)*VoiceThresholdStep, MaxVoiceThreshold))
+	case 10: // Voice Hangover
+		m.config.VoiceHangover = math.Max(MinVoiceHangover, math.Min(m.config.VoiceHangover+float64(delta)*VoiceHangoverStep, MaxVoiceHangover))
+	case 11: // Silence Auto-Stop
+		currentIdx := 0
+		for i, timeout := range silenceTimeouts {
+			if timeout == m.config.SilenceTimeout {
+				currentIdx = i
+			}
+		}
+		nextIdx := (currentIdx + delta + len(silenceTimeouts)) % len(silenceTimeouts)
+		m.config.SilenceTimeout = silenceTimeouts[nextIdx]
+	case 12: // Trim Silence
+		m.config.TrimSilence = !m.config.TrimSilence
+	case 13: // Normalize, cycling through the targets and off
+		currentIdx := len(loudnessTargets)
+		if m.config.Normalize {
+			for i, target := range loudnessTargets {
+				if target == m.config.TargetLoudness {
+					currentIdx = i
+				}
+			}
+		}
+		nextIdx := (currentIdx + delta + len(loudnessTargets) + 1) % (len(loudnessTargets) + 1)
+		m.config.Normalize = nextIdx < len(loudnessTargets)
+		if m.config.Normalize {
+			m.config.TargetLoudness = loudnessTargets[nextIdx]
+		}
+	case 14: // Keep Original
+		m.config.KeepOriginal = !m.config.KeepOriginal
+	case 15: // Noise Reduction
+		m.config.NoiseReduction = !m.config.NoiseReduction
+	case 16: // Hum Filter
+		currentIdx := 0
+		for i, freq := range humFrequencies {
+			if freq == m.config.HumFrequency {
+				currentIdx = i
+			}
+		}
+		nextIdx := (currentIdx + delta + len(humFrequencies)) % len(humFrequencies)
+		m.config.HumFrequency = humFrequencies[nextIdx]
+	case 17: // Pre-roll
+		currentIdx := 0
+		for i, seconds := range preRollTimes {
+			if seconds == m.config.PreRollTime {
+				currentIdx = i
+			}
+		}
+		nextIdx := (currentIdx + delta + len(preRollTimes)) % len(preRollTimes)
+		m.config.PreRollTime = preRollTimes[nextIdx]
 	}
 }
 
@@ -1126,7 +1438,7 @@ func (m *Model) selectSetting() {
 		// Refresh available devices lazily then force fresh detection
 		m.initializeAudioDevices()
 		// Force a fresh detection by clearing and re-detecting
-		m.config.AudioDevices = detectAudioDevices()
+		m.config.AudioDevices = detectAudioDevices(m.backend)
 		m.availableDevices = m.config.AudioDevices
 		setDefaultDevices(&m.config)
 	default:
@@ -1138,7 +1450,7 @@ func (m *Model) selectSetting() {
 func (m *Model) initializeAudioDevices() {
 	if len(m.config.AudioDevices) == 0 {
 		log.Printf("Initializing audio devices...")
-		m.config.AudioDevices = detectAudioDevices()
+		m.config.AudioDevices = detectAudioDevices(m.backend)
 		m.availableDevices = m.config.AudioDevices
 		setDefaultDevices(&m.config)
 
@@ -1203,13 +1515,49 @@ func (m Model) handleTextInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// Handle the prompt to import orphaned memos
+func (m Model) handleRecoveryKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Yes), key.Matches(msg, keys.Enter):
+		m.importOrphanedMemos()
+		m.state = StateViewing
+
+	case key.Matches(msg, keys.No), key.Matches(msg, keys.Escape):
+		// The files stay where they are and are offered again next time
+		m.orphanedMemos = nil
+		m.state = StateViewing
+
+	case key.Matches(msg, keys.Quit):
+		return m, m.quit()
+	}
+
+	return m, nil
+}
+
+// Handle the prompt to delete the originals of an edited or merged memo
+func (m Model) handleConfirmEditKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Yes):
+		m.confirmEdit(true)
+
+	case key.Matches(msg, keys.No), key.Matches(msg, keys.Escape):
+		m.confirmEdit(false)
+
+	case key.Matches(msg, keys.Quit):
+		// Quitting without an answer keeps the original
+		return m, m.quit()
+	}
+
+	return m, nil
+}
+
 // Handle main keyboard input
 func (m Model) handleMainKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch {
 	case key.Matches(msg, keys.Quit):
-		return m, tea.Quit
+		return m, m.quit()
 
 	case key.Matches(msg, keys.Help):
 		m.help.ShowAll = !m.help.ShowAll
@@ -1222,10 +1570,17 @@ func (m Model) handleMainKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case key.Matches(msg, keys.Record):
 		if m.recording {
-			m.stopRecording()
+			cmds = append(cmds, m.stopRecording())
 		} else {
-			m.startRecording()
-			cmds = append(cmds, recordingTick())
+			cmds = append(cmds, m.startRecording())
+			if m.audioDevice != nil {
+				cmds = append(cmds, listenForLevels(m.audioDevice.levels))
+			}
+		}
+
+	case key.Matches(msg, keys.PauseRecording):
+		if m.recording {
+			m.toggleRecordingPause()
 		}
 
 	case key.Matches(msg, keys.Play):
@@ -1238,10 +1593,63 @@ func (m Model) handleMainKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 	case key.Matches(msg, keys.Stop):
-		if m.playing {
+		if m.playing || m.playbackPaused() {
 			m.stopPlayback()
 		}
 
+	case key.Matches(msg, keys.SeekBack):
+		m.seekPlayback(-seekShort)
+
+	case key.Matches(msg, keys.SeekForward):
+		m.seekPlayback(seekShort)
+
+	case key.Matches(msg, keys.SeekBackLong):
+		m.seekPlayback(-seekLong)
+
+	case key.Matches(msg, keys.SeekForwardLong):
+		m.seekPlayback(seekLong)
+
+	case key.Matches(msg, keys.JumpTo):
+		m.seekPlaybackFraction(float64(msg.String()[0]-'0') / 10)
+
+	case key.Matches(msg, keys.SpeedUp):
+		m.setPlaybackSpeed(m.playbackSpeed + PlaybackSpeedStep)
+
+	case key.Matches(msg, keys.SpeedDown):
+		m.setPlaybackSpeed(m.playbackSpeed - PlaybackSpeedStep)
+
+	case key.Matches(msg, keys.MarkIn):
+		m.markIn()
+
+	case key.Matches(msg, keys.MarkOut):
+		m.markOut()
+
+	case key.Matches(msg, keys.ClearMarks):
+		m.clearMarks()
+
+	case key.Matches(msg, keys.Trim):
+		cmds = append(cmds, m.trimMemo())
+
+	case key.Matches(msg, keys.Split):
+		cmds = append(cmds, m.splitMemo())
+
+	case key.Matches(msg, keys.ToggleSelect):
+		m.toggleSelected()
+
+	case key.Matches(msg, keys.Merge):
+		cmds = append(cmds, m.mergeSelected())
+
+	case key.Matches(msg, keys.Denoise):
+		cmds = append(cmds, m.denoiseSelected())
+
+	case key.Matches(msg, keys.Transcribe):
+		m.transcribeSelected()
+
+	case key.Matches(msg, keys.Search):
+		if !m.recording {
+			m.startSearch()
+		}
+
 	case key.Matches(msg, keys.Up), key.Matches(msg, keys.Down):
 		// Let the list handle navigation
 		var cmd tea.Cmd
@@ -1277,209 +1685,134 @@ func (m Model) handleMainKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 	case key.Matches(msg, keys.Escape):
-		return m, tea.Quit
+		return m, m.quit()
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
-// Update waveform visualization (simulated)
-func (m *Model) updateWaveform() {
-	// Generate random waveform data for demo
-	samples := make([]float32, 100)
-	var max float32
-	for i := range samples {
-		val := float32(i%20-10) / 10.0
-		samples[i] = val
-		if val < 0 {
-			val = -val
-		}
-		if val > max {
-			max = val
-		}
+// Quit, or once stopped recordings and a running edit are saved if there
+// are any, since quitting now would lose their processing. Asking again
+// quits right away.
+func (m *Model) quit() tea.Cmd {
+	if m.quitting || (m.savingRecordings == 0 && m.editing == "") {
+		return tea.Quit
 	}
-	m.waveform = WaveformData{samples: samples, max: max}
+	m.quitting = true
+	m.showNotification("Finishing before quitting, press q again to quit now")
+	return nil
+}
 
-	// Update VU meter
-	m.vuMeter = VUMeterData{
-		leftLevel:  0.7,
-		rightLevel: 0.8,
+// Quit if quitting was put off and nothing is left to save
+func (m *Model) quitWhenSaved() tea.Cmd {
+	if m.quitting && m.savingRecordings == 0 && m.editing == "" {
+		return tea.Quit
 	}
+	return nil
 }
 
-// Start recording
-func (m *Model) startRecording() {
+// Start recording. If it fails to start, returns the command that saves
+// whatever was set up, as stopRecording does.
+func (m *Model) startRecording() tea.Cmd {
 	// Initialize audio devices if not already done
 	m.initializeAudioDevices()
 
+	// Release a paused playback stream before reusing the audio device
+	if m.playbackPaused() {
+		m.stopPlayback()
+	}
+
 	m.recording = true
+	m.recordingPaused = false
 	m.state = StateRecording
 	m.recordingTime = 0
+	m.waveform = WaveformData{}
+	m.vuMeter = VUMeterData{}
 	m.lastUpdate = time.Now()
 
-	// Initialize PortAudio
-	if err := portaudio.Initialize(); err != nil {
-		log.Printf("Error initializing PortAudio: %v", err)
-		m.stopRecording()
-		return
-	}
-
-	// Find selected input device
-	var inputDev *portaudio.DeviceInfo
-	if m.config.InputDevice != "" {
-		inputDev = getDeviceByID(m.config.InputDevice)
-		log.Printf("Selected input device ID: %s", m.config.InputDevice)
-		if inputDev != nil {
-			log.Printf("Found input device: %s (channels: %d)", inputDev.Name, inputDev.MaxInputChannels)
-		} else {
-			log.Printf("Could not find input device with ID: %s", m.config.InputDevice)
-		}
-	}
-
-	// Fallback to default input device
-	if inputDev == nil {
-		inputDev, _ = portaudio.DefaultInputDevice()
-		log.Printf("Using default input device")
-		if inputDev != nil {
-			log.Printf("Default input device: %s (channels: %d)", inputDev.Name, inputDev.MaxInputChannels)
+	// Create audio device
+	m.audioDevice = &AudioDevice{}
+
+	// Continue from the pre-roll stream when it is listening, or open an
+	// input stream on the selected device
+	log.Printf("Selected input device ID: %s", m.config.InputDevice)
+	preRoll := m.takePreRoll()
+	var stream AudioStream
+	if preRoll != nil {
+		stream = preRoll.stream
+		m.audioDevice.preRoll = preRoll
+	} else {
+		var err error
+		stream, err = m.backend.OpenInputStream(m.inputStreamConfig(), m.audioDevice.processInput)
+		if err != nil {
+			log.Printf("Error opening recording stream: %v", err)
+			return m.stopRecording()
 		}
 	}
 
-	if inputDev == nil {
-		log.Printf("No input device available")
-		m.stopRecording()
-		return
-	}
+	m.audioDevice.stream = stream
 
-	// Create recording file
+	// Create recording file. Compressed formats are captured as PCM first
+	// and encoded when recording stops.
 	filename := generateFilename(m.config.DefaultFormat)
 	filePath := filepath.Join(m.config.MemosPath, filename)
+	if m.config.DefaultFormat != FormatWAV {
+		m.audioDevice.encodePath = filePath
+		filePath = capturePath(filePath)
+	}
 	file, err := os.Create(filePath)
 	if err != nil {
 		log.Printf("Error creating recording file: %v", err)
-		m.stopRecording()
-		return
+		return m.stopRecording()
 	}
 
-	// Write WAV header (we'll update the data size later)
-	if err := writeWAVHeader(file, m.config.SampleRate, m.config.ChannelCount, m.config.BitDepth*8, 0); err != nil {
-		log.Printf("Error writing WAV header: %v", err)
-		m.stopRecording()
-		return
-	}
+	m.audioDevice.recordingFile = file
 
-	// Set up audio parameters - try to use device's preferred format
-	params := portaudio.HighLatencyParameters(inputDev, nil)
-
-	// Try to use device's preferred sample rate, fallback to config
-	if inputDev.DefaultSampleRate > 0 {
-		params.SampleRate = inputDev.DefaultSampleRate
-		log.Printf("Using device's preferred sample rate: %.0f Hz", params.SampleRate)
-	} else {
-		params.SampleRate = float64(m.config.SampleRate)
-		log.Printf("Using config sample rate: %.0f Hz", params.SampleRate)
+	// The file is stored at the configured rate whatever rate the device
+	// delivers, converting on the fly when they differ
+	m.audioDevice.captureFormat = wavInfo{
+		Channels:      stream.Channels(),
+		SampleRate:    m.config.SampleRate,
+		BitsPerSample: m.config.BitDepth,
+		BlockAlign:    stream.Channels() * m.config.BitDepth / 8,
 	}
-
-	// Try to use device's preferred channel count, fallback to config
-	if inputDev.MaxInputChannels > 0 {
-		// Use minimum of device max and our config
-		channels := m.config.ChannelCount
-		if inputDev.MaxInputChannels < channels {
-			channels = inputDev.MaxInputChannels
-		}
-		params.Input.Channels = channels
-		log.Printf("Using %d input channels (device max: %d, config: %d)",
-			channels, inputDev.MaxInputChannels, m.config.ChannelCount)
-	} else {
-		params.Input.Channels = m.config.ChannelCount
-		log.Printf("Using config channel count: %d", params.Input.Channels)
+	m.audioDevice.resampler = newResampler(stream.SampleRate(), m.config.SampleRate, stream.Channels())
+	if stream.SampleRate() != m.config.SampleRate {
+		log.Printf("Resampling input from %d Hz to %d Hz", stream.SampleRate(), m.config.SampleRate)
 	}
 
-	params.FramesPerBuffer = 1024
-
-	// Create audio device
-	m.audioDevice = &AudioDevice{
-		recordingFile: file,
+	// Write WAV header (we'll update the data size later)
+	if err := writeWAVHeader(file, m.config.SampleRate, stream.Channels(), m.config.BitDepth, 0); err != nil {
+		log.Printf("Error writing WAV header: %v", err)
+		return m.stopRecording()
 	}
 
-	// Open input stream
-	stream, err := portaudio.OpenStream(params, m.processAudioInput)
-	if err != nil {
-		log.Printf("Error opening recording stream: %v", err)
-		m.stopRecording()
-		return
+	if m.config.VoiceActivated {
+		m.audioDevice.voiceGate = newVoiceGate(m.config, stream.SampleRate(), stream.Channels())
 	}
+	m.audioDevice.startCapture(stream.SampleRate(), stream.Channels())
 
-	m.audioDevice.stream = stream
-
-	// Start recording
-	if err := stream.Start(); err != nil {
+	// Start recording. The pre-roll stream is already running and only has
+	// to be pointed at the recording.
+	if preRoll != nil {
+		preRoll.target.Store(m.audioDevice)
+		log.Printf("Recording started from pre-roll (%s, %d Hz, %d channels)",
+			m.backend.Name(), stream.SampleRate(), stream.Channels())
+	} else if err := stream.Start(); err != nil {
 		log.Printf("Error starting recording: %v", err)
-		m.stopRecording()
+		return m.stopRecording()
 	} else {
-		log.Printf("Recording started successfully with device: %s", inputDev.Name)
+		log.Printf("Recording started successfully (%s, %d Hz, %d channels)",
+			m.backend.Name(), stream.SampleRate(), stream.Channels())
 	}
+	return nil
 }
 
-// Process audio input callback
-func (m *Model) processAudioInput(in []int16) {
-	// Debug: Check if we're getting any audio data
-	if len(in) > 0 {
-		// Check for non-zero samples (actual audio)
-		hasAudio := false
-		for _, sample := range in {
-			if sample != 0 {
-				hasAudio = true
-				break
-			}
-		}
-
-		// Log first few samples for debugging
-		if len(in) >= 4 {
-			log.Printf("Audio samples: [%d, %d, %d, %d] (hasAudio: %v)",
-				in[0], in[1], in[2], in[3], hasAudio)
-		}
-	}
-
-	// Write audio data to file
-	if m.audioDevice != nil && m.audioDevice.recordingFile != nil {
-		if err := binary.Write(m.audioDevice.recordingFile, binary.LittleEndian, in); err != nil {
-			log.Printf("Error writing audio data: %v", err)
-		}
-	}
-
-	// Update waveform visualization
-	if len(in) > 0 {
-		samples := make([]float32, len(in))
-		var max float32
-		for i, sample := range in {
-			// Convert int16 to float32 (-1.0 to 1.0)
-			val := float32(sample) / 32768.0
-			samples[i] = val
-			if val < 0 {
-				val = -val
-			}
-			if val > max {
-				max = val
-			}
-		}
-		m.waveform = WaveformData{samples: samples, max: max}
-
-		// Update VU meter (simplified - use first few samples)
-		if len(in) >= 2 {
-			leftLevel := float32(in[0]) / 32768.0
-			rightLevel := float32(in[1]) / 32768.0
-			if leftLevel < 0 {
-				leftLevel = -leftLevel
-			}
-			if rightLevel < 0 {
-				rightLevel = -rightLevel
-			}
-			m.vuMeter = VUMeterData{
-				leftLevel:  leftLevel,
-				rightLevel: rightLevel,
-			}
-		}
+// Encode captured frames and append them to the recording file
+func (d *AudioDevice) writeCapture(frames []float32) {
+	d.captureBuf = appendPCM(d.captureBuf[:0], frames, d.captureFormat.BitsPerSample)
+	if _, err := d.recordingFile.Write(d.captureBuf); err != nil {
+		log.Printf("Error writing audio data: %v", err)
 	}
 }
 
@@ -1497,34 +1830,56 @@ func (m *Model) processAudioOutput(out []int16) {
 	volume := m.config.Volume
 
 	// Fill output buffer with audio data
+	device := m.audioDevice
+	start := device.playbackPos.Load()
+
+	// Other speeds go through the time stretcher, which restarts from the
+	// current position whenever it moved since the last buffer
+	if speed := device.speed(); speed != 1 {
+		if start != device.stretcher.reported {
+			device.stretcher.reset(int(start) / device.playbackChans)
+		}
+		pos := device.stretcher.process(out, device.playbackData, speed, volume)
+		device.stretcher.reported = pos
+		device.playbackPos.CompareAndSwap(start, pos)
+		return
+	}
+
+	pos := int(start)
 	for i := range out {
-		if m.audioDevice.playbackPos < len(m.audioDevice.playbackData) {
+		if pos < len(device.playbackData) {
 			// Apply volume and copy sample
-			sample := float64(m.audioDevice.playbackData[m.audioDevice.playbackPos]) * volume
+			sample := float64(device.playbackData[pos]) * volume
 			if sample > 32767 {
 				sample = 32767
 			} else if sample < -32768 {
 				sample = -32768
 			}
 			out[i] = int16(sample)
-			m.audioDevice.playbackPos++
+			pos++
 		} else {
 			// End of audio data - fill with silence
 			out[i] = 0
 		}
 	}
 
+	// A seek made while this buffer was filled takes precedence
+	device.playbackPos.CompareAndSwap(start, int64(pos))
+
 	// Note: End-of-playback detection is handled in the main thread (tick handler)
 	// to avoid issues with stopping the stream from within the callback
 }
 
-// Stop recording and save memo
-func (m *Model) stopRecording() {
+// Stop recording and save the memo. The recording file is closed here;
+// noise reduction, trimming, normalization and encoding take a while for
+// a long memo, so they run in the returned command and the memo is added
+// when its recordingSavedMsg arrives. Returns nil if nothing was recorded.
+func (m *Model) stopRecording() tea.Cmd {
 	m.recording = false
+	m.recordingPaused = false
 	m.state = StateViewing
 
-	var filename string
-	var fileSize int64
+	var capturePath, encodePath string
 	var duration float64
 
 	// Clean up audio device and finalize recording
@@ -1539,71 +1894,166 @@ func (m *Model) stopRecording() {
 			}
 		}
 
+		// Write out the audio still queued for the disk writer
+		m.audioDevice.finishCapture()
+
 		// Finalize the WAV file
 		if m.audioDevice.recordingFile != nil {
 			// Get file info
 			fileInfo, _ := m.audioDevice.recordingFile.Stat()
-			filename = fileInfo.Name()
-			fileSize = fileInfo.Size()
 
 			// Calculate actual duration
-			// WAV file size minus header (44 bytes) divided by bytes per sample
-			dataSize := fileSize - 44
-			bytesPerSample := m.config.ChannelCount * m.config.BitDepth
-			if bytesPerSample > 0 && m.config.SampleRate > 0 {
-				samples := dataSize / int64(bytesPerSample)
-				duration = float64(samples) / float64(m.config.SampleRate)
+			// WAV file size minus header divided by bytes per frame
+			dataSize := fileInfo.Size() - wavHeaderSize
+			capture := m.audioDevice.captureFormat
+			if capture.BlockAlign > 0 && capture.SampleRate > 0 {
+				frames := dataSize / int64(capture.BlockAlign)
+				duration = float64(frames) / float64(capture.SampleRate)
 			} else {
 				// Fallback calculation
 				duration = m.recordingTime.Seconds()
 			}
 
-			// Update WAV header with correct data size
-			if _, err := m.audioDevice.recordingFile.Seek(40, 0); err != nil {
-				log.Printf("Error seeking in recording file: %v", err)
-			}
-			if err := binary.Write(m.audioDevice.recordingFile, binary.LittleEndian, uint32(dataSize)); err != nil {
-				log.Printf("Error writing data size: %v", err)
+			// Update WAV header with correct RIFF and data sizes
+			if err := finalizeWAVHeader(m.audioDevice.recordingFile); err != nil {
+				log.Printf("Error finalizing WAV header: %v", err)
 			}
 
 			// Close the file
 			m.audioDevice.recordingFile.Close()
+
+			capturePath = m.audioDevice.recordingFile.Name()
+			encodePath = m.audioDevice.encodePath
 		}
 
 		m.audioDevice = nil
 	}
 
-	// Terminate PortAudio
-	if err := portaudio.Terminate(); err != nil {
-		log.Printf("Error terminating PortAudio: %v", err)
+	// Reset recording data
+	m.recordingTime = 0
+
+	if capturePath == "" {
+		return nil
+	}
+	m.savingRecordings++
+	config := m.config
+	return func() tea.Msg {
+		return saveRecording(capturePath, encodePath, duration, config)
 	}
+}
+
+// recordingSavedMsg delivers a recording saved in the background: the new
+// memo, the unprocessed original if one was kept, and any problem to
+// tell the user about
+type recordingSavedMsg struct {
+	memo     Memo
+	original *Memo
+	notice   string
+}
 
-	// Create new memo with real data
-	if filename != "" {
-		memo := Memo{
-			ID:       fmt.Sprintf("%d", time.Now().Unix()),
-			Filename: filename,
-			Name:     strings.TrimSuffix(filename, filepath.Ext(filename)),
-			Duration: duration,
-			Created:  time.Now(),
-			Size:     fileSize,
-			Tags:     []string{},
-			Format:   m.config.DefaultFormat.String(),
+// Post-process and encode a closed recording file of the given duration as
+// configured, and describe the result as a memo
+func saveRecording(capturePath, encodePath string, duration float64, config Config) recordingSavedMsg {
+	var msg recordingSavedMsg
+
+	// Reduce noise, trim and normalize before any encoding
+	processed, original, err := postProcessCapture(capturePath, duration, config)
+	if err != nil {
+		log.Printf("Error post-processing recording: %v", err)
+		msg.notice = fmt.Sprintf("Post-processing failed: %v", err)
+	}
+	msg.original = original
+	duration = processed
+
+	// Encode compressed formats now that the capture is complete
+	path, format := capturePath, FormatWAV
+	if encodePath != "" {
+		path, format, err = encodeCapture(capturePath, encodePath, config)
+		if err != nil {
+			log.Printf("Error encoding recording to %s: %v", config.DefaultFormat, err)
+			msg.notice = fmt.Sprintf("%s encoding failed, saved as WAV", config.DefaultFormat)
 		}
+	}
 
-		// Add to memos list
-		m.memos = append([]Memo{memo}, m.memos...)
-		// Refresh list items to include the new memo
-		m.memoList.SetItems(convertMemosToListItems(m.memos))
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	filename := filepath.Base(path)
+	msg.memo = Memo{
+		ID:       newMemoID(),
+		Filename: filename,
+		Name:     strings.TrimSuffix(filename, filepath.Ext(filename)),
+		Duration: duration,
+		Created:  time.Now(),
+		Size:     size,
+		Tags:     []string{},
+		Format:   format.String(),
+	}
+	return msg
+}
+
+// Add a recording saved in the background to the memo list
+func (m *Model) handleRecordingSaved(msg recordingSavedMsg) {
+	m.savingRecordings--
+
+	saved := []Memo{msg.memo}
+	if msg.original != nil {
+		saved = append(saved, *msg.original)
+	}
+	m.memos = append(saved, m.memos...)
+	m.memoList.SetItems(convertMemosToListItems(m.memos))
+	m.updateStore(saved)
+	if msg.notice != "" {
+		m.showNotification(msg.notice)
+	}
+
+	// Transcribe in the background when an engine is configured
+	m.queueTranscription(msg.memo.Filename)
+}
 
-		// Save metadata
-		if err := saveMemos(m.memos, m.config.MemosPath); err != nil {
-			log.Printf("Error saving memos metadata: %v", err)
+// Encode a finished PCM capture into the configured format and return the
+// resulting file. If encoding fails the capture is kept as a WAV memo and
+// the error is returned with its path.
+func encodeCapture(capture, target string, config Config) (string, AudioFormat, error) {
+	format := config.DefaultFormat
+	if err := encodeAudioFile(capture, target, format, config); err != nil {
+		wavPath := strings.TrimSuffix(target, filepath.Ext(target)) + FormatWAV.Extension()
+		if renameErr := os.Rename(capture, wavPath); renameErr != nil {
+			log.Printf("Error renaming capture: %v", renameErr)
+			return capture, FormatWAV, err
 		}
+		return wavPath, FormatWAV, err
 	}
 
-	// Reset recording data
-	m.recordingTime = 0
+	if err := os.Remove(capture); err != nil {
+		log.Printf("Error removing capture: %v", err)
+	}
+	log.Printf("Encoded recording to %s: %s", format, target)
+	return target, format, nil
+}
+
+// Pause or resume writing the recording. The stream and file stay open, so
+// a resumed recording continues the same memo.
+func (m *Model) toggleRecordingPause() {
+	if m.audioDevice == nil {
+		return
+	}
+	// Count the time recorded since the last tick, and none while paused
+	now := time.Now()
+	if !m.recordingPaused {
+		m.recordingTime += now.Sub(m.lastUpdate)
+	}
+	m.lastUpdate = now
+
+	m.recordingPaused = !m.recordingPaused
+	m.audioDevice.capturePaused.Store(m.recordingPaused)
+
+	if m.recordingPaused {
+		log.Printf("Recording paused at %s", formatDuration(m.recordingTime))
+	} else {
+		log.Printf("Recording resumed at %s", formatDuration(m.recordingTime))
+	}
 }
 
 // Start playback
@@ -1618,68 +2068,64 @@ func (m *Model) startPlayback() {
 	memo := m.memos[m.selectedIdx]
 	filePath := filepath.Join(m.config.MemosPath, memo.Filename)
 
-	// Read WAV file data
-	audioData, sampleRate, channels, err := readWAVData(filePath)
-	if err != nil {
-		log.Printf("Error reading audio file: %v", err)
-		return
-	}
-
-	// Initialize PortAudio
-	if err := portaudio.Initialize(); err != nil {
-		log.Printf("Error initializing PortAudio: %v", err)
-		return
-	}
-
-	// Find selected output device
-	var outputDev *portaudio.DeviceInfo
-	if m.config.OutputDevice != "" {
-		outputDev = getDeviceByID(m.config.OutputDevice)
-	}
-
-	// Fallback to default output device
-	if outputDev == nil {
-		outputDev, _ = portaudio.DefaultOutputDevice()
+	// Resume a paused memo where it left off
+	if m.playbackPaused() {
+		if m.audioDevice.playbackMemo == memo.Filename {
+			m.resumePlayback()
+			return
+		}
+		m.stopPlayback()
 	}
 
-	if outputDev == nil {
-		log.Printf("No output device available")
-		if err := portaudio.Terminate(); err != nil {
-			log.Printf("Error terminating PortAudio: %v", err)
+	// Read and decode audio file data
+	audioData, sampleRate, channels, err := readAudioData(filePath)
+	if err != nil {
+		log.Printf("Error reading audio file: %v", err)
+		var unsupported *UnsupportedWAVError
+		if errors.As(err, &unsupported) {
+			m.showNotification(fmt.Sprintf("Cannot play memo: %v", unsupported))
 		}
 		return
 	}
 
-	// Set up audio parameters
-	params := portaudio.HighLatencyParameters(nil, outputDev)
-	params.SampleRate = float64(sampleRate)
-	params.Output.Channels = channels
-	params.FramesPerBuffer = 1024
-
 	// Create audio device
 	m.audioDevice = &AudioDevice{
 		playbackData: audioData,
-		playbackPos:  0,
+		playbackMemo: memo.Filename,
 	}
 
-	// Open output stream
-	stream, err := portaudio.OpenStream(params, m.processAudioOutput)
+	// Open output stream on the selected device
+	stream, err := m.backend.OpenOutputStream(StreamConfig{
+		DeviceID:        m.config.OutputDevice,
+		SampleRate:      sampleRate,
+		Channels:        channels,
+		FramesPerBuffer: 1024,
+	}, m.processAudioOutput)
 	if err != nil {
 		log.Printf("Error opening playback stream: %v", err)
-		if err := portaudio.Terminate(); err != nil {
-			log.Printf("Error terminating PortAudio: %v", err)
-		}
+		m.audioDevice = nil
 		return
 	}
 
 	m.audioDevice.stream = stream
 
+	// Convert to the rate the output device is running at
+	if stream.SampleRate() != sampleRate {
+		log.Printf("Resampling playback from %d Hz to %d Hz", sampleRate, stream.SampleRate())
+		m.audioDevice.playbackData = resampleInt16(audioData, channels, sampleRate, stream.SampleRate())
+	}
+	m.audioDevice.playbackRate = stream.SampleRate()
+	m.audioDevice.playbackChans = stream.Channels()
+	m.audioDevice.stretcher = newTimeStretcher(stream.SampleRate(), stream.Channels())
+	m.audioDevice.setSpeed(m.playbackSpeed)
+
 	// Start playback
 	if err := stream.Start(); err != nil {
 		log.Printf("Error starting playback: %v", err)
-		if err := portaudio.Terminate(); err != nil {
-			log.Printf("Error terminating PortAudio: %v", err)
+		if err := stream.Close(); err != nil {
+			log.Printf("Error closing playback stream: %v", err)
 		}
+		m.audioDevice = nil
 		return
 	}
 
@@ -1703,6 +2149,18 @@ func (m *Model) pausePlayback() {
 	log.Printf("Playback paused")
 }
 
+// Resume paused playback from its current position
+func (m *Model) resumePlayback() {
+	if err := m.audioDevice.stream.Start(); err != nil {
+		log.Printf("Error resuming playback: %v", err)
+		return
+	}
+	m.playing = true
+	m.state = StatePlaying
+	m.lastUpdate = time.Now()
+	log.Printf("Playback resumed at %s", formatDuration(m.audioDevice.playbackTime()))
+}
+
 // Stop playback
 func (m *Model) stopPlayback() {
 	if m.audioDevice != nil {
@@ -1718,11 +2176,6 @@ func (m *Model) stopPlayback() {
 		m.audioDevice = nil
 	}
 
-	// Terminate PortAudio
-	if err := portaudio.Terminate(); err != nil {
-		log.Printf("Error terminating PortAudio: %v", err)
-	}
-
 	m.playing = false
 	m.state = StateViewing
 	m.playbackPos = 0
@@ -1747,9 +2200,7 @@ func (m *Model) renameMemo(newName string) {
 		// Refresh list items to reflect rename without resetting scroll elsewhere
 		m.memoList.SetItems(convertMemosToListItems(m.memos))
 
-		if err := saveMemos(m.memos, m.config.MemosPath); err != nil {
-			log.Printf("Error saving memos metadata: %v", err)
-		}
+		m.updateStore([]Memo{*memo})
 	}
 }
 
@@ -1778,9 +2229,7 @@ func (m *Model) addTag(tag string) {
 		// Refresh list items to reflect tag change
 		m.memoList.SetItems(convertMemosToListItems(m.memos))
 
-		if err := saveMemos(m.memos, m.config.MemosPath); err != nil {
-			log.Printf("Error saving memos metadata: %v", err)
-		}
+		m.updateStore([]Memo{*memo})
 	}
 }
 
@@ -1789,35 +2238,73 @@ func (m *Model) deleteMemo() {
 	if len(m.memos) == 0 {
 		return
 	}
+	m.removeMemos([]Memo{m.memos[m.selectedIdx]})
+}
 
-	memo := m.memos[m.selectedIdx]
-
-	// Remove from file system
-	filePath := filepath.Join(m.config.MemosPath, memo.Filename)
-	os.Remove(filePath)
+// Delete memos and their files and take them off the list. Returns how
+// many were deleted.
+func (m *Model) removeMemos(memos []Memo) int {
+	for _, memo := range memos {
+		if m.audioDevice != nil && m.audioDevice.playbackMemo == memo.Filename {
+			m.stopPlayback()
+		}
+	}
 
-	// Remove from memos list
-	for i, mem := range m.memos {
-		if mem.ID == memo.ID {
-			m.memos = append(m.memos[:i], m.memos[i+1:]...)
-			break
+	deleted, err := deleteMemos(m.store, m.config.MemosPath, memos)
+	if err != nil {
+		log.Printf("Error deleting memos: %v", err)
+		m.showNotification(fmt.Sprintf("Deleting failed: %v", err))
+	}
+	for _, filename := range deleted {
+		delete(m.transcripts, filename)
+		delete(m.peakCache, filename)
+		for i, memo := range m.memos {
+			if memo.Filename == filename {
+				m.memos = append(m.memos[:i], m.memos[i+1:]...)
+				break
+			}
 		}
 	}
 
 	// Adjust selection
-	if m.selectedIdx >= len(m.memos) {
-		m.selectedIdx = len(m.memos) - 1
+	m.selectedIdx = max(0, min(m.selectedIdx, len(m.memos)-1))
+
+	// Refresh list items to reflect deletion without losing scroll position
+	m.memoList.SetItems(convertMemosToListItems(m.memos))
+	return len(deleted)
+}
+
+// Delete memos with their audio, transcripts and cached peaks, and remove
+// them from the store. This is the one way memos are deleted, from the UI
+// and the command line. Memos whose audio can't be deleted are kept;
+// the filenames of the others are returned along with the first error.
+func deleteMemos(store MemoStore, memosPath string, memos []Memo) ([]string, error) {
+	var deleted []string
+	var firstErr error
+	for _, memo := range memos {
+		err := os.Remove(filepath.Join(memosPath, memo.Filename))
+		if err != nil && !os.IsNotExist(err) {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := os.Remove(transcriptPath(memosPath, memo.Filename)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error deleting transcript: %v", err)
+		}
+		deleted = append(deleted, memo.Filename)
 	}
-	if m.selectedIdx < 0 {
-		m.selectedIdx = 0
+	if len(deleted) == 0 {
+		return nil, firstErr
 	}
 
-	if err := saveMemos(m.memos, m.config.MemosPath); err != nil {
-		log.Printf("Error saving memos metadata: %v", err)
+	if err := removeCachedPeaks(memosPath, deleted); err != nil {
+		log.Printf("Error updating peak cache: %v", err)
 	}
-
-	// Refresh list items to reflect deletion without losing scroll position
-	m.memoList.SetItems(convertMemosToListItems(m.memos))
+	if err := store.Update(nil, deleted); err != nil {
+		return deleted, err
+	}
+	return deleted, firstErr
 }
 
 // Export memo
@@ -1902,17 +2389,39 @@ func (m Model) renderSettings() string {
 		"Bit Depth:",
 		"Channels:",
 		"Audio Format:",
+		"Bitrate:",
 		"Volume:",
+		"Voice Activation:",
+		"Voice Threshold:",
+		"Voice Hangover:",
+		"Silence Auto-Stop:",
+		"Trim Silence:",
+		"Normalize:",
+		"Keep Original:",
+		"Noise Reduction:",
+		"Hum Filter:",
+		"Pre-roll:",
 	}
 
 	values := []string{
 		m.getDeviceName(m.config.InputDevice),
 		m.getDeviceName(m.config.OutputDevice),
 		fmt.Sprintf("%d Hz", m.config.SampleRate),
-		fmt.Sprintf("%d-bit", m.config.BitDepth),
+		bitDepthLabel(m.config.BitDepth),
 		fmt.Sprintf("%d", m.config.ChannelCount),
-		m.config.DefaultFormat.String(),
+		formatLabel(m.config),
+		bitrateLabel(m.config),
 		fmt.Sprintf("%.0f%%", m.getPlayerVolume()*100),
+		onOffLabel(m.config.VoiceActivated),
+		fmt.Sprintf("%.0f dBFS", m.config.VoiceThreshold),
+		fmt.Sprintf("%.1f s", m.config.VoiceHangover),
+		secondsLabel(m.config.SilenceTimeout),
+		onOffLabel(m.config.TrimSilence),
+		normalizeLabel(m.config),
+		onOffLabel(m.config.KeepOriginal),
+		onOffLabel(m.config.NoiseReduction),
+		humLabel(m.config.HumFrequency),
+		secondsLabel(m.config.PreRollTime),
 	}
 
 	var lines []string
@@ -1940,7 +2449,7 @@ func (m Model) renderSettings() string {
 
 	// System info
 	sections = append(sections, "")
-	sections = append(sections, mutedStyle.Render(getSystemAudioInfo()))
+	sections = append(sections, mutedStyle.Render(getSystemAudioInfo(m.backend)))
 
 	// Instructions
 	instructions := []string{
@@ -1975,9 +2484,58 @@ func (m Model) getDeviceName(deviceID string) string {
 }
 
 // Get system audio info
-func getSystemAudioInfo() string {
-	// Avoid initializing PortAudio here to prevent strict init/term cycles on some platforms
-	return "Audio system: Ready"
+func getSystemAudioInfo(backend AudioBackend) string {
+	// Avoid opening the backend here to prevent strict init/term cycles on some platforms
+	return fmt.Sprintf("Audio system: Ready (%s)", backend.Name())
+}
+
+// Describe the recording format
+func formatLabel(config Config) string {
+	if config.DefaultFormat == FormatOGG {
+		return fmt.Sprintf("%s Vorbis", config.DefaultFormat)
+	}
+	return config.DefaultFormat.String()
+}
+
+// Describe the bitrate of the recording format; WAV has none to set
+func bitrateLabel(config Config) string {
+	switch config.DefaultFormat {
+	case FormatMP3:
+		return fmt.Sprintf("%d kbps", config.MP3Bitrate)
+	case FormatOGG:
+		return fmt.Sprintf("%d kbps", config.OggBitrate)
+	default:
+		return "Uncompressed"
+	}
+}
+
+// Step the bitrate of the recording format through the rates it offers:
+// the MPEG table at the recording sample rate for MP3, oggBitrates for
+// Ogg Vorbis
+func (c *Config) adjustBitrate(delta int) {
+	step := func(rates []int, current int) int {
+		currentIdx := 0
+		for i, rate := range rates {
+			if abs(rate-current) < abs(rates[currentIdx]-current) {
+				currentIdx = i
+			}
+		}
+		return rates[(currentIdx+delta+len(rates))%len(rates)]
+	}
+	switch c.DefaultFormat {
+	case FormatMP3:
+		c.MP3Bitrate = step(mp3Bitrates(c.SampleRate)[1:], c.MP3Bitrate)
+	case FormatOGG:
+		c.OggBitrate = step(oggBitrates, c.OggBitrate)
+	}
+}
+
+// Describe the recording bit depth; 32-bit recordings are stored as float
+func bitDepthLabel(bits int) string {
+	if bits == 32 {
+		return "32-bit float"
+	}
+	return fmt.Sprintf("%d-bit", bits)
 }
 
 // Get player volume (for settings display)
@@ -2003,18 +2561,33 @@ func (m Model) renderMain() string {
 	sections = append(sections, m.renderHeader())
 
 	// Waveform/VU meters section
-	if m.recording || m.playing {
+	if m.recording || m.playing || m.playbackPaused() {
 		sections = append(sections, m.renderAudioVisualizer())
 	}
 
-	// Main content area with memo list and speaker art
-	sections = append(sections, m.renderMainContent())
+	// Main content area with memo list and speaker art, or the search
+	// box and its results
+	if m.state == StateSearching {
+		sections = append(sections, m.renderTextInput(), m.renderSearchResults(max(20, m.width-6), max(3, m.height-18)))
+	} else {
+		sections = append(sections, m.renderMainContent())
+	}
 
 	// Text input (for renaming/tagging)
 	if m.state == StateRenaming || m.state == StateTagging {
 		sections = append(sections, m.renderTextInput())
 	}
 
+	// Prompt to import memos recovered at startup
+	if m.state == StateRecovering {
+		sections = append(sections, m.renderRecoveryPrompt())
+	}
+
+	// Prompt to delete the originals of an edited or merged memo
+	if m.state == StateConfirmEdit && m.pendingEdit != nil {
+		sections = append(sections, m.renderConfirmEditPrompt())
+	}
+
 	// Status bar
 	sections = append(sections, m.renderStatusBar())
 
@@ -2033,14 +2606,27 @@ func (m Model) renderHeader() string {
 			indicator = " "
 		}
 		status = recordingStyle.Render(fmt.Sprintf("%s REC %s", indicator, formatDuration(m.recordingTime)))
+		if m.recordingPaused {
+			status = recordingStyle.Render(fmt.Sprintf("❚❚ PAUSED %s", formatDuration(m.recordingTime)))
+		} else if m.voiceGateClosed() {
+			status = mutedStyle.Render(fmt.Sprintf("○ LISTENING %s", formatDuration(m.recordingTime)))
+		}
 	case StatePlaying:
-		status = successStyle.Render("▶ PLAYING")
+		status = successStyle.Render("▶ PLAYING" + speedLabel(m.playbackSpeed))
 	default:
-		if len(m.memos) == 1 {
+		if m.playbackPaused() {
+			status = normalStyle.Render("❚❚ PAUSED" + speedLabel(m.playbackSpeed))
+		} else if n := len(m.selectedMemos()); n > 0 {
+			status = normalStyle.Render(fmt.Sprintf("%d of %d memos selected", n, len(m.memos)))
+		} else if len(m.memos) == 1 {
 			status = normalStyle.Render("1 memo")
 		} else {
 			status = normalStyle.Render(fmt.Sprintf("%d memos", len(m.memos)))
 		}
+		if m.preRoll != nil {
+			// The microphone is open outside a recording
+			status += "  " + mutedStyle.Render(fmt.Sprintf("◉ PRE-ROLL %s", secondsLabel(m.preRoll.length)))
+		}
 	}
 
 	// Create header content
@@ -2055,52 +2641,38 @@ func (m Model) renderAudioVisualizer() string {
 	var lines []string
 
 	if m.recording {
-		// Waveform
-		waveformLine := "Waveform: "
-		for _, sample := range m.waveform.samples[:min(50, len(m.waveform.samples))] {
-			height := int((sample + 1) * 5)
-			if height < 0 {
-				height = 0
-			}
-			if height > 10 {
-				height = 10
-			}
-
-			if height > 7 {
-				waveformLine += "█"
-			} else if height > 5 {
-				waveformLine += "▆"
-			} else if height > 3 {
-				waveformLine += "▄"
-			} else if height > 1 {
-				waveformLine += "▂"
-			} else {
-				waveformLine += "·"
-			}
+		// Waveform across the full width, inside the border and padding
+		for _, line := range renderWaveform(m.waveform, max(10, m.width-6), waveformRows) {
+			lines = append(lines, waveformStyle.Render(line))
 		}
-		lines = append(lines, waveformStyle.Render(waveformLine))
 
 		// VU Meters
-		leftMeter := renderVUMeter("L", m.vuMeter.leftLevel)
-		rightMeter := renderVUMeter("R", m.vuMeter.rightLevel)
-		lines = append(lines, vuMeterStyle.Render(leftMeter))
-		lines = append(lines, vuMeterStyle.Render(rightMeter))
+		lines = append(lines, renderVUMeter("L", m.vuMeter.left))
+		lines = append(lines, renderVUMeter("R", m.vuMeter.right))
 	}
 
-	if m.playing && len(m.memos) > 0 {
-		// Timeline scrubber
-		memo := m.memos[m.selectedIdx]
-		progress := m.playbackPos.Seconds() / memo.Duration
-		if progress > 1 {
-			progress = 1
+	if (m.playing || m.playbackPaused()) && m.audioDevice != nil {
+		// Timeline scrubber, measured against the audio actually loaded
+		length := m.audioDevice.playbackLength()
+		var progress float64
+		if length > 0 {
+			progress = math.Min(m.playbackPos.Seconds()/length.Seconds(), 1)
 		}
 
-		timeline := renderTimeline(progress, 50)
+		timeline := renderTimeline(progress, m.timelineWidth())
 		timeDisplay := fmt.Sprintf("%s / %s",
 			formatDuration(m.playbackPos),
-			formatDuration(time.Duration(memo.Duration*float64(time.Second))))
+			formatDuration(length))
+		if m.playbackSpeed != 1 {
+			// Positions are in memo time; also show how long is left to listen
+			remaining := time.Duration(float64(max(0, length-m.playbackPos)) / m.playbackSpeed)
+			timeDisplay += fmt.Sprintf("  ·  %s left at%s", formatDuration(remaining), speedLabel(m.playbackSpeed))
+		}
 
 		lines = append(lines, successStyle.Render(timeline))
+		if m.selectionMemo == m.audioDevice.playbackMemo && !m.selection.empty() && length > 0 {
+			lines = append(lines, recordingStyle.Render(renderTimelineMarks(m.selection, length, m.timelineWidth())))
+		}
 		lines = append(lines, mutedStyle.Render(timeDisplay))
 	}
 
@@ -2116,24 +2688,42 @@ func (m Model) renderAudioVisualizer() string {
 	return ""
 }
 
-// Render VU meter
-func renderVUMeter(label string, level float32) string {
+// Render VU meter: the RMS level as a bar on a dBFS scale, with a marker
+// at the held peak and a clip indicator
+func renderVUMeter(label string, level meterChannel) string {
 	barWidth := 30
-	filled := int(level * float32(barWidth))
+	position := func(linear float64) int {
+		return int((dBFS(linear) - vuFloorDB) / -vuFloorDB * float64(barWidth))
+	}
+	filled := position(level.rms)
+	hold := min(position(level.peakHold), barWidth-1)
 
 	bar := label + ": ["
 	for i := 0; i < barWidth; i++ {
-		if i < filled {
-			if level > 0.8 {
-				bar += "█"
-			} else if level > 0.6 {
-				bar += "▆"
-			} else {
-				bar += "▄"
-			}
+		// Level at the top of this cell
+		db := vuFloorDB + float64(i+1)/float64(barWidth)*-vuFloorDB
+		style := vuMeterStyle
+		if db > vuHotDB {
+			style = vuMeterHotStyle
+		} else if db > vuWarnDB {
+			style = vuMeterWarnStyle
+		}
+
+		switch {
+		case i < filled:
+			bar += style.Render("█")
+		case i == hold && level.peakHold > 0:
+			bar += style.Render("│")
+		default:
+			bar += " "
 		}
 	}
-	return bar + "]"
+	bar += "]" + mutedStyle.Render(fmt.Sprintf(" %5.1f dBFS  peak %5.1f", dBFS(level.rms), dBFS(level.peakHold)))
+
+	if level.clipped {
+		bar += " " + clipStyle.Render("CLIP")
+	}
+	return bar
 }
 
 // Render timeline scrubber
@@ -2207,6 +2797,20 @@ func (m Model) renderMainContent() string {
 		memoListContent = memoListBorderStyle.Render(m.memoList.View())
 	}
 
+	// Show the selected memo's waveform beside the list when there is room,
+	// after the spacer and the pane's border and padding
+	overviewWidth := m.width - lipgloss.Width(memoListContent) - 4 - 6
+	if len(m.memos) > 0 && overviewWidth >= 20 {
+		details := m.renderOverview(overviewWidth)
+
+		// The transcript takes the height left under the waveform, inside
+		// its own border and padding
+		if rows := lipgloss.Height(memoListContent) - lipgloss.Height(details) - 4; rows >= 3 {
+			details = lipgloss.JoinVertical(lipgloss.Left, details, m.renderTranscript(overviewWidth, rows))
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Top, memoListContent, "    ", details)
+	}
+
 	// Style the speaker art with two-tone colors
 	speakerArtText := m.renderTwoToneSpeakerArt(speakerArt)
 
@@ -2296,6 +2900,8 @@ func (m Model) renderTextInput() string {
 		prompt = "New name: "
 	case StateTagging:
 		prompt = "Add tag: "
+	case StateSearching:
+		prompt = "Search: "
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left,
@@ -2305,15 +2911,62 @@ func (m Model) renderTextInput() string {
 	)
 }
 
+// Render recovery prompt
+func (m Model) renderRecoveryPrompt() string {
+	return lipgloss.JoinVertical(lipgloss.Left,
+		"",
+		recordingStyle.Render(fmt.Sprintf("Found %d unfinished recording(s) without metadata.", len(m.orphanedMemos))),
+		normalStyle.Render("Import them as memos? (y/n)"),
+		"",
+	)
+}
+
+// Render the prompt shown after a trim, split or merge
+func (m Model) renderConfirmEditPrompt() string {
+	edit := m.pendingEdit
+	question := "Delete the original memo? (y/n)"
+	if len(edit.originals) > 1 {
+		question = fmt.Sprintf("Delete the %d original memos? (y/n)", len(edit.originals))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		"",
+		successStyle.Render(fmt.Sprintf("Created %s from %s.", quotedNames(edit.created), quotedNames(edit.originals))),
+		normalStyle.Render(question),
+		"",
+	)
+}
+
+// List memo names in quotes, e.g. "a", "b" and "c"
+func quotedNames(memos []Memo) string {
+	names := make([]string, len(memos))
+	for i, memo := range memos {
+		names[i] = fmt.Sprintf("%q", memo.Name)
+	}
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
 // Render status bar
 func (m Model) renderStatusBar() string {
 	var status string
 
 	switch {
+	case m.recordingPaused:
+		status = recordingStyle.Render("❚❚ RECORDING PAUSED")
+	case m.voiceGateClosed():
+		status = normalStyle.Render("○ WAITING FOR VOICE")
 	case m.recording:
 		status = recordingStyle.Render("● RECORDING")
 	case m.playing:
-		status = successStyle.Render("▶ PLAYING")
+		status = successStyle.Render("▶ PLAYING" + speedLabel(m.playbackSpeed))
+	case m.quitting:
+		status = normalStyle.Render("⧗ Finishing…")
+	case m.editing != "":
+		status = normalStyle.Render("⧗ " + m.editing + "…")
+	case m.savingRecordings > 0:
+		status = normalStyle.Render("⧗ Processing recording…")
 	default:
 		status = normalStyle.Render("Ready")
 	}
@@ -2341,12 +2994,29 @@ func (m Model) renderStatusBar() string {
 // Main function
 func main() {
 	setupLogging()
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+	}
 	log.Printf("Starting voicelog application")
 
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	m, err := initialModel()
+	if err != nil {
+		log.Printf("Error opening memo store: %v", err)
+		fmt.Fprintf(os.Stderr, "%s: %v\n", AppName, err)
+		os.Exit(1)
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	final, err := p.Run()
+	if err != nil {
 		log.Printf("Error running voicelog: %v", err)
 		fmt.Printf("Error running voicelog: %v\n", err)
 		os.Exit(1)
 	}
+	if m, ok := final.(Model); ok {
+		if m.preRoll != nil {
+			m.preRoll.close()
+		}
+		m.stopTranscriber()
+	}
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Select or deselect the highlighted memo for merging
+func (m *Model) toggleSelected() {
+	if len(m.memos) == 0 {
+		return
+	}
+	memo := &m.memos[m.selectedIdx]
+	memo.Selected = !memo.Selected
+	m.memoList.SetItem(m.selectedIdx, *memo)
+}
+
+// The memos selected for merging, oldest first
+func (m Model) selectedMemos() []Memo {
+	var selected []Memo
+	for _, memo := range m.memos {
+		if memo.Selected {
+			selected = append(selected, memo)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Created.Before(selected[j].Created)
+	})
+	return selected
+}
+
+// Concatenate the selected memos in recording order into a new memo, in
+// the background
+func (m *Model) mergeSelected() tea.Cmd {
+	sources := m.selectedMemos()
+	if len(sources) < 2 {
+		m.showNotification("Select at least two memos to merge")
+		return nil
+	}
+	if !m.startEdit("Merging") {
+		return nil
+	}
+
+	for i := range m.memos {
+		if m.memos[i].Selected {
+			m.memos[i].Selected = false
+			m.memoList.SetItem(i, m.memos[i])
+		}
+	}
+	for i := range sources {
+		sources[i].Selected = false
+	}
+
+	memosPath := m.config.MemosPath
+	return func() tea.Msg {
+		merged, err := mergeMemos(memosPath, sources)
+		if err != nil {
+			return editDoneMsg{action: "Merge", err: err}
+		}
+		return editDoneMsg{action: "Merge", originals: sources, created: []Memo{merged}}
+	}
+}
+
+// Write the sources one after another to a new WAV file and describe it as
+// a memo dated by the first of them
+func mergeMemos(memosPath string, sources []Memo) (Memo, error) {
+	first := sources[0]
+	base := strings.TrimSuffix(generateFilename(FormatWAV), FormatWAV.Extension())
+	dstPath := uniquePath(filepath.Join(memosPath, base+"_merged"+FormatWAV.Extension()))
+
+	var paths []string
+	for _, memo := range sources {
+		paths = append(paths, filepath.Join(memosPath, memo.Filename))
+	}
+	duration, err := writeMergedWAV(paths, dstPath)
+	if err != nil {
+		os.Remove(dstPath)
+		return Memo{}, err
+	}
+	info, err := os.Stat(dstPath)
+	if err != nil {
+		return Memo{}, err
+	}
+
+	// Tags of all sources, each once, in the order they first appear
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, memo := range sources {
+		for _, tag := range memo.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return Memo{
+		ID:       newMemoID(),
+		Filename: filepath.Base(dstPath),
+		Name:     fmt.Sprintf("%s (merged)", first.Name),
+		Duration: duration,
+		Created:  first.Created,
+		Size:     info.Size(),
+		Tags:     tags,
+		Format:   FormatWAV.String(),
+	}, nil
+}
+
+// Write audio files one after another to a new WAV file and return its
+// duration in seconds. Everything is converted to the highest sample rate,
+// channel count and sample size among the sources, so merging 24-bit or
+// float memos keeps their precision.
+func writeMergedWAV(paths []string, dstPath string) (float64, error) {
+	type clip struct {
+		samples              []float32
+		sampleRate, channels int
+	}
+
+	var clips []clip
+	sampleRate, channels, bits := 0, 0, 0
+	for _, path := range paths {
+		samples, rate, ch, clipBits, err := readAudioFloat(path)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		clips = append(clips, clip{samples, rate, ch})
+		sampleRate = max(sampleRate, rate)
+		channels = max(channels, ch)
+		bits = max(bits, clipBits)
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	if err := writeWAVHeader(dst, sampleRate, channels, bits, wavUnknownSize); err != nil {
+		return 0, err
+	}
+
+	var frames int
+	for _, c := range clips {
+		samples := convertChannels(c.samples, c.channels, channels)
+		samples = resampleFloat(samples, channels, c.sampleRate, sampleRate)
+		frames += len(samples) / channels
+
+		if _, err := dst.Write(appendPCM(nil, samples, bits)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := finalizeWAVHeader(dst); err != nil {
+		return 0, err
+	}
+	return float64(frames) / float64(sampleRate), dst.Close()
+}
+
+// Convert interleaved samples to another channel count. A mix down to mono
+// averages the channels; otherwise channels are repeated in order to fill
+// the output, so mono is copied to every channel.
+func convertChannels[T int16 | float32](samples []T, from, to int) []T {
+	if from == to {
+		return samples
+	}
+
+	frames := len(samples) / from
+	out := make([]T, frames*to)
+	for f := 0; f < frames; f++ {
+		frame := samples[f*from : (f+1)*from]
+		if to == 1 {
+			var sum float64
+			for _, s := range frame {
+				sum += float64(s)
+			}
+			out[f] = T(sum / float64(from))
+			continue
+		}
+		for c := 0; c < to; c++ {
+			out[f*to+c] = frame[c%from]
+		}
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Merged memos take the highest sample rate, channel count and sample size
+// among the sources
+func TestWriteMergedWAV(t *testing.T) {
+	dir := t.TempDir()
+	tone := func(frames, channels int) []float32 {
+		samples := make([]float32, frames*channels)
+		for i := range samples {
+			samples[i] = 0.25
+		}
+		return samples
+	}
+
+	tests := []struct {
+		name    string
+		sources []struct{ rate, channels, bits int }
+		want    wavInfo
+	}{
+		{
+			name:    "24-bit and 16-bit",
+			sources: []struct{ rate, channels, bits int }{{44100, 1, 24}, {44100, 1, 16}},
+			want:    wavInfo{FormatTag: wavFormatPCM, Channels: 1, SampleRate: 44100, BitsPerSample: 24},
+		},
+		{
+			name:    "float and 24-bit stereo",
+			sources: []struct{ rate, channels, bits int }{{48000, 1, 32}, {48000, 2, 24}},
+			want:    wavInfo{FormatTag: wavFormatIEEEFloat, Channels: 2, SampleRate: 48000, BitsPerSample: 32},
+		},
+		{
+			name:    "mixed rates",
+			sources: []struct{ rate, channels, bits int }{{16000, 1, 16}, {48000, 1, 16}},
+			want:    wavInfo{FormatTag: wavFormatPCM, Channels: 1, SampleRate: 48000, BitsPerSample: 16},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			var wantSeconds float64
+			for i, src := range tt.sources {
+				path := filepath.Join(dir, tt.name+string(rune('a'+i))+".wav")
+				if err := writeWAVFile(path, tone(src.rate/2, src.channels), src.rate, src.channels, src.bits); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+				wantSeconds += 0.5
+			}
+
+			dst := filepath.Join(dir, tt.name+"_merged.wav")
+			duration, err := writeMergedWAV(paths, dst)
+			if err != nil {
+				t.Fatalf("writeMergedWAV: %v", err)
+			}
+			if duration < wantSeconds-0.01 || duration > wantSeconds+0.01 {
+				t.Errorf("duration = %.3fs, want %.3fs", duration, wantSeconds)
+			}
+
+			samples, info, err := readWAVFloat(dst)
+			if err != nil {
+				t.Fatalf("reading merged file: %v", err)
+			}
+			if info.FormatTag != tt.want.FormatTag || info.Channels != tt.want.Channels ||
+				info.SampleRate != tt.want.SampleRate || info.BitsPerSample != tt.want.BitsPerSample {
+				t.Errorf("merged format = %+v, want %+v", info, tt.want)
+			}
+			// Away from the joins the level is untouched
+			if v := samples[len(samples)/4]; v < 0.249 || v > 0.251 {
+				t.Errorf("sample = %v, want 0.25", v)
+			}
+		})
+	}
+}
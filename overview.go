@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Waveform overview settings
+const (
+	overviewResolution = 1024 // Peaks stored per memo, independent of its length
+	overviewRows       = 5    // Braille rows in the overview pane
+)
+
+// memoPeaks is the cached overview of one memo: the loudest sample in each
+// of overviewResolution equal spans, scaled to 0-255. Size identifies the
+// file contents the peaks were computed from.
+type memoPeaks struct {
+	Size  int64  `json:"size"`
+	Peaks []byte `json:"peaks"`
+}
+
+// timeRange is a span of a memo, such as the region selected for trimming
+type timeRange struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// Whether the range covers no audio
+func (r timeRange) empty() bool {
+	return r.end <= r.start
+}
+
+// overviewMsg delivers peaks computed in the background
+type overviewMsg struct {
+	filename string
+	peaks    memoPeaks
+	err      error
+}
+
+// Load the peak cache kept next to the memo metadata
+func loadPeakCache(memosPath string) map[string]memoPeaks {
+	cache := make(map[string]memoPeaks)
+	data, err := os.ReadFile(filepath.Join(memosPath, PeaksFile))
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		log.Printf("Error unmarshaling peak cache: %v", err)
+		return make(map[string]memoPeaks)
+	}
+	return cache
+}
+
+// Save the peak cache, dropping entries for memos that no longer exist
+func savePeakCache(cache map[string]memoPeaks, memos []Memo, memosPath string) error {
+	current := make(map[string]memoPeaks, len(memos))
+	for _, memo := range memos {
+		if peaks, ok := cache[memo.Filename]; ok {
+			current[memo.Filename] = peaks
+		}
+	}
+
+	return writePeakCache(current, memosPath)
+}
+
+// Drop the cached peaks of deleted memos
+func removeCachedPeaks(memosPath string, filenames []string) error {
+	cache := loadPeakCache(memosPath)
+	changed := false
+	for _, filename := range filenames {
+		if _, ok := cache[filename]; ok {
+			delete(cache, filename)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return writePeakCache(cache, memosPath)
+}
+
+func writePeakCache(cache map[string]memoPeaks, memosPath string) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(memosPath, PeaksFile), data, 0644)
+}
+
+// Read a memo's audio and reduce it to overview peaks
+func computePeaks(filePath string) (memoPeaks, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return memoPeaks{}, err
+	}
+	samples, _, _, err := readAudioData(filePath)
+	if err != nil {
+		return memoPeaks{}, err
+	}
+
+	peaks := make([]byte, overviewResolution)
+	if len(samples) > 0 {
+		for i := range peaks {
+			start := i * len(samples) / len(peaks)
+			end := max(start+1, (i+1)*len(samples)/len(peaks))
+			var peak float64
+			for _, s := range samples[start:min(end, len(samples))] {
+				peak = math.Max(peak, math.Abs(float64(s)))
+			}
+			peaks[i] = byte(math.Round(math.Min(peak/32768, 1) * 255))
+		}
+	}
+	return memoPeaks{Size: info.Size(), Peaks: peaks}, nil
+}
+
+// Compute a memo's peaks off the UI goroutine
+func loadOverview(memosPath string, memo Memo) tea.Cmd {
+	return func() tea.Msg {
+		peaks, err := computePeaks(filepath.Join(memosPath, memo.Filename))
+		return overviewMsg{filename: memo.Filename, peaks: peaks, err: err}
+	}
+}
+
+// The selected memo and its cached peaks, if they are current
+func (m Model) selectedOverview() (Memo, memoPeaks, bool) {
+	if m.selectedIdx < 0 || m.selectedIdx >= len(m.memos) {
+		return Memo{}, memoPeaks{}, false
+	}
+	memo := m.memos[m.selectedIdx]
+	peaks, ok := m.peakCache[memo.Filename]
+	return memo, peaks, ok && peaks.Size == memo.Size
+}
+
+// Start computing the selected memo's overview if it is missing or stale
+func (m *Model) requestOverview() tea.Cmd {
+	memo, _, ok := m.selectedOverview()
+	if ok || memo.Filename == "" || m.overviewPending[memo.Filename] || m.recording {
+		return nil
+	}
+	m.overviewPending[memo.Filename] = true
+	return loadOverview(m.config.MemosPath, memo)
+}
+
+// Store computed peaks in the cache
+func (m *Model) handleOverview(msg overviewMsg) {
+	delete(m.overviewPending, msg.filename)
+	if msg.err != nil {
+		log.Printf("Error computing overview of %s: %v", msg.filename, msg.err)
+		// Remember the failure so it is not retried on every tick
+		m.peakCache[msg.filename] = memoPeaks{Size: m.memoSize(msg.filename)}
+		return
+	}
+
+	m.peakCache[msg.filename] = msg.peaks
+
+	// Correct a size recorded before the file was final, so the peaks are
+	// seen as current
+	for i, memo := range m.memos {
+		if memo.Filename == msg.filename && memo.Size != msg.peaks.Size {
+			m.memos[i].Size = msg.peaks.Size
+			m.memoList.SetItem(i, m.memos[i])
+		}
+	}
+
+	if err := savePeakCache(m.peakCache, m.memos, m.config.MemosPath); err != nil {
+		log.Printf("Error saving peak cache: %v", err)
+	}
+}
+
+// Size recorded for a memo file, or -1 if it is not in the list
+func (m Model) memoSize(filename string) int64 {
+	for _, memo := range m.memos {
+		if memo.Filename == filename {
+			return memo.Size
+		}
+	}
+	return -1
+}
+
+// Render the overview pane for the selected memo: its whole waveform, the
+// part already played and the playhead, and the marked selection
+func (m Model) renderOverview(width int) string {
+	memo, peaks, ok := m.selectedOverview()
+	if memo.Filename == "" {
+		return ""
+	}
+	width = max(10, width)
+
+	title := normalStyle.Render(truncateText(memo.Name, max(10, width-12)))
+	duration := mutedStyle.Render(formatDuration(time.Duration(memo.Duration * float64(time.Second))))
+	gap := max(1, width-lipgloss.Width(title)-lipgloss.Width(duration))
+	lines := []string{title + strings.Repeat(" ", gap) + duration, ""}
+
+	switch {
+	case !ok:
+		lines = append(lines, mutedStyle.Render("Reading waveform..."))
+	case len(peaks.Peaks) == 0:
+		lines = append(lines, mutedStyle.Render("Waveform unavailable"))
+	default:
+		lines = append(lines, m.renderOverviewWaveform(memo, peaks, width)...)
+	}
+
+	return memoListBorderStyle.Render(strings.Join(lines, "\n"))
+}
+
+// Draw the peaks as braille, styling columns by playback and selection
+func (m Model) renderOverviewWaveform(memo Memo, peaks memoPeaks, width int) []string {
+	// Two envelope slices per character, each the loudest of its peaks
+	slices := make([]envelopeSlice, width*2)
+	for i := range slices {
+		start := i * len(peaks.Peaks) / len(slices)
+		end := max(start+1, (i+1)*len(peaks.Peaks)/len(slices))
+		var peak byte
+		for _, p := range peaks.Peaks[start:min(end, len(peaks.Peaks))] {
+			peak = max(peak, p)
+		}
+		level := float32(peak) / 255
+		slices[i] = envelopeSlice{min: -level, max: level}
+	}
+	rows := renderWaveform(WaveformData{slices: slices}, width, overviewRows)
+
+	// Column of a position in the memo
+	length := time.Duration(memo.Duration * float64(time.Second))
+	column := func(t time.Duration) int {
+		if length <= 0 {
+			return 0
+		}
+		return int(float64(t) / float64(length) * float64(width))
+	}
+
+	playhead := -1
+	if (m.playing || m.playbackPaused()) && m.audioDevice != nil && m.audioDevice.playbackMemo == memo.Filename {
+		length = m.audioDevice.playbackLength()
+		playhead = min(column(m.playbackPos), width-1)
+	}
+
+	selStart, selEnd := -1, -1
+	selected := m.selectionMemo == memo.Filename && !m.selection.empty()
+	if selected {
+		selStart, selEnd = column(m.selection.start), max(column(m.selection.end), column(m.selection.start)+1)
+	}
+
+	// Style class of a column: played, playhead or ahead, inside the
+	// selection or not
+	class := func(col int) int {
+		c := 0
+		switch {
+		case col == playhead:
+			c = 2
+		case col < playhead:
+			c = 1
+		}
+		if col >= selStart && col < selEnd {
+			c += 3
+		}
+		return c
+	}
+	styles := [6]lipgloss.Style{waveformStyle, successStyle, overviewPlayheadStyle}
+	for c := 0; c < 3; c++ {
+		styles[c+3] = styles[c].Background(lipgloss.Color(Border))
+	}
+
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		cells := []rune(row)
+		var b strings.Builder
+		for col := 0; col < len(cells); {
+			// Render runs of equally styled columns together
+			end := col + 1
+			for end < len(cells) && class(end) == class(col) {
+				end++
+			}
+			b.WriteString(styles[class(col)].Render(string(cells[col:end])))
+			col = end
+		}
+		lines[i] = b.String()
+	}
+
+	// Time axis under the waveform, with the selection when there is one
+	left, right := formatDuration(0), formatDuration(length)
+	if selected {
+		right = fmt.Sprintf("selection %s-%s  %s", formatDuration(m.selection.start), formatDuration(m.selection.end), right)
+	}
+	axis := left + strings.Repeat(" ", max(1, width-len(left)-len(right))) + right
+	return append(lines, mutedStyle.Render(axis))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Playback seek steps
+const (
+	seekShort = 5 * time.Second
+	seekLong  = 30 * time.Second
+)
+
+// Position of the playback in progress or paused
+func (d *AudioDevice) playbackTime() time.Duration {
+	return d.samplesToTime(d.playbackPos.Load())
+}
+
+// Length of the audio loaded for playback
+func (d *AudioDevice) playbackLength() time.Duration {
+	return d.samplesToTime(int64(len(d.playbackData)))
+}
+
+func (d *AudioDevice) samplesToTime(samples int64) time.Duration {
+	samplesPerSecond := d.playbackRate * d.playbackChans
+	if samplesPerSecond <= 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(samplesPerSecond) * float64(time.Second))
+}
+
+// Whether a memo is loaded for playback but paused
+func (m Model) playbackPaused() bool {
+	return !m.playing && !m.recording && m.audioDevice != nil && m.audioDevice.playbackData != nil
+}
+
+// Move the playback position by offset, clamped to the memo
+func (m *Model) seekPlayback(offset time.Duration) {
+	if m.audioDevice == nil || m.audioDevice.playbackData == nil {
+		return
+	}
+	m.seekPlaybackTo(m.audioDevice.playbackTime() + offset)
+}
+
+// Move the playback position to a fraction of the memo's length
+func (m *Model) seekPlaybackFraction(fraction float64) {
+	if m.audioDevice == nil || m.audioDevice.playbackData == nil {
+		return
+	}
+	m.seekPlaybackTo(time.Duration(fraction * float64(m.audioDevice.playbackLength())))
+}
+
+// Move the playback position to t, keeping it on a frame boundary. Works
+// while playing or paused.
+func (m *Model) seekPlaybackTo(t time.Duration) {
+	device := m.audioDevice
+	if device == nil || device.playbackData == nil || device.playbackChans <= 0 {
+		return
+	}
+
+	frames := len(device.playbackData) / device.playbackChans
+	frame := int(t.Seconds() * float64(device.playbackRate))
+	frame = max(0, min(frame, frames))
+	device.playbackPos.Store(int64(frame * device.playbackChans))
+	m.playbackPos = device.playbackTime()
+}
+
+// Width of the playback timeline bar for the current terminal width
+func (m Model) timelineWidth() int {
+	// Leave room for the visualizer border and padding and the brackets
+	return max(10, m.width-8)
+}
+
+// Seek when the timeline is clicked or dragged
+func (m *Model) handleMouse(msg tea.MouseMsg) {
+	if !m.playing && !m.playbackPaused() {
+		return
+	}
+	if msg.Button != tea.MouseButtonLeft || (msg.Action != tea.MouseActionPress && msg.Action != tea.MouseActionMotion) {
+		return
+	}
+
+	// The timeline is the first line inside the visualizer box, below the
+	// header, the box border and its padding. The bar starts after the
+	// border, the padding and the opening bracket.
+	row := lipgloss.Height(m.renderHeader()) + 3
+	left := 4
+	width := m.timelineWidth()
+	if msg.Y != row || msg.X < left-1 || msg.X > left+width {
+		return
+	}
+
+	fraction := (float64(msg.X-left) + 0.5) / float64(width)
+	m.seekPlaybackFraction(math.Max(0, math.Min(fraction, 1)))
+}
+
+// Playback speed read by the output callback
+func (d *AudioDevice) speed() float64 {
+	if bits := d.playbackSpeed.Load(); bits != 0 {
+		return math.Float64frombits(bits)
+	}
+	return 1
+}
+
+func (d *AudioDevice) setSpeed(speed float64) {
+	d.playbackSpeed.Store(math.Float64bits(speed))
+}
+
+// Change the playback speed, clamped to the supported range. It applies to
+// the current playback and any started later.
+func (m *Model) setPlaybackSpeed(speed float64) {
+	m.playbackSpeed = clampSpeed(speed)
+	if m.audioDevice != nil && m.audioDevice.stretcher != nil {
+		m.audioDevice.setSpeed(m.playbackSpeed)
+	}
+	m.showNotification(fmt.Sprintf("Playback speed %gx", m.playbackSpeed))
+}
+
+// Speed suffix for status text, empty at normal speed
+func speedLabel(speed float64) string {
+	if speed == 1 {
+		return ""
+	}
+	return " " + strconv.FormatFloat(speed, 'f', -1, 64) + "x"
+}
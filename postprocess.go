@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Post-processing defaults
+const (
+	DefaultTrimThreshold  = -50.0 // dBFS
+	DefaultTargetLoudness = -16.0 // LUFS, usual for spoken word
+
+	trimPadding      = 250 * time.Millisecond // Audio kept around the first and last sound
+	normalizeCeiling = -1.0                   // Highest sample peak after normalization, in dBFS
+)
+
+// Loudness targets offered in the settings, in LUFS
+var loudnessTargets = []float64{-23, -19, -16, -14}
+
+// EBU R128 / ITU-R BS.1770-4 loudness measurement
+const (
+	loudnessBlock        = 400 * time.Millisecond // Gating block length
+	loudnessStep         = 100 * time.Millisecond // Blocks overlap by 75%
+	loudnessAbsoluteGate = -70.0                  // LUFS
+	loudnessRelativeGate = -10.0                  // LU below the absolutely gated loudness
+)
+
+// Reduce noise, trim and normalize a finished recording in place as
+// configured, keeping its sample format. Returns the new duration in
+// seconds.
+func processRecording(filePath string, config Config) (float64, error) {
+	samples, info, err := readWAVFloat(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	// Noise reduction learns its profile from the start of the recording,
+	// so it runs before trimming removes that
+	if config.NoiseReduction {
+		reduceNoise(samples, info.Channels, info.SampleRate, config)
+	}
+	if config.TrimSilence {
+		before := len(samples)
+		samples = trimSilence(samples, info.Channels, info.SampleRate, config.TrimThreshold)
+		log.Printf("Trimmed %.2fs of silence", float64(before-len(samples))/float64(info.Channels*info.SampleRate))
+	}
+	if config.Normalize {
+		loudness := integratedLoudness(samples, info.Channels, info.SampleRate)
+		gain := normalizeLoudness(samples, loudness, config.TargetLoudness)
+		log.Printf("Normalized from %.1f LUFS with %+.1f dB gain", loudness, gain)
+	}
+
+	if err := writeWAVFile(filePath, samples, info.SampleRate, info.Channels, info.outputBits()); err != nil {
+		return 0, err
+	}
+	return float64(len(samples)/info.Channels) / float64(info.SampleRate), nil
+}
+
+// Cut the audio before the first and after the last frame with a sample at
+// or above thresholdDB, leaving trimPadding on both sides. Audio that never
+// reaches the threshold is left alone rather than removed entirely.
+func trimSilence(samples []float32, channels, sampleRate int, thresholdDB float64) []float32 {
+	threshold := float32(math.Pow(10, thresholdDB/20))
+	frames := len(samples) / channels
+
+	loud := func(frame int) bool {
+		for _, v := range samples[frame*channels : (frame+1)*channels] {
+			if v >= threshold || -v >= threshold {
+				return true
+			}
+		}
+		return false
+	}
+
+	first := 0
+	for first < frames && !loud(first) {
+		first++
+	}
+	if first == frames {
+		return samples
+	}
+	last := frames - 1
+	for last > first && !loud(last) {
+		last--
+	}
+
+	padding := int(trimPadding.Seconds() * float64(sampleRate))
+	first = max(0, first-padding)
+	last = min(frames-1, last+padding)
+	return samples[first*channels : (last+1)*channels]
+}
+
+// Measure integrated loudness in LUFS as BS.1770-4 defines it: K-weighted
+// mean square over 400 ms blocks, gated absolutely at -70 LUFS and then
+// 10 LU below the loudness of the remaining blocks. All channels are
+// weighted equally, as for left, right and centre. Returns -Inf for
+// silence or audio shorter than one block.
+func integratedLoudness(samples []float32, channels, sampleRate int) float64 {
+	frames := len(samples) / channels
+	step := int(loudnessStep.Seconds() * float64(sampleRate))
+	stepsPerBlock := int(loudnessBlock / loudnessStep)
+	if step == 0 || frames < stepsPerBlock*step {
+		return math.Inf(-1)
+	}
+
+	// Energy of each 100 ms step of K-weighted audio, summed over channels
+	steps := make([]float64, frames/step)
+	for c := 0; c < channels; c++ {
+		filter := newKWeighting(sampleRate)
+		for i := range steps {
+			for f := i * step; f < (i+1)*step; f++ {
+				y := filter.process(float64(samples[f*channels+c]))
+				steps[i] += y * y
+			}
+		}
+	}
+
+	// Mean square of each block, one block starting at every step
+	blocks := make([]float64, len(steps)-stepsPerBlock+1)
+	for i := range blocks {
+		for _, energy := range steps[i : i+stepsPerBlock] {
+			blocks[i] += energy
+		}
+		blocks[i] /= float64(stepsPerBlock * step)
+	}
+
+	gated := func(threshold float64) float64 {
+		var sum float64
+		n := 0
+		for _, z := range blocks {
+			if blockLoudness(z) > threshold {
+				sum += z
+				n++
+			}
+		}
+		if n == 0 {
+			return math.Inf(-1)
+		}
+		return blockLoudness(sum / float64(n))
+	}
+
+	absolute := gated(loudnessAbsoluteGate)
+	if math.IsInf(absolute, -1) {
+		return absolute
+	}
+	return gated(absolute + loudnessRelativeGate)
+}
+
+// Loudness of a channel-summed mean square
+func blockLoudness(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+// Apply the gain that brings loudness to target, reduced if needed so no
+// sample peaks above normalizeCeiling. Returns the gain applied in dB.
+func normalizeLoudness(samples []float32, loudness, target float64) float64 {
+	if math.IsInf(loudness, -1) {
+		return 0
+	}
+
+	var peak float64
+	for _, v := range samples {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	gainDB := target - loudness
+	if peak > 0 {
+		gainDB = math.Min(gainDB, normalizeCeiling-20*math.Log10(peak))
+	}
+
+	gain := float32(math.Pow(10, gainDB/20))
+	for i := range samples {
+		samples[i] *= gain
+	}
+	return gainDB
+}
+
+// biquad is a direct form I second-order filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting is the BS.1770 K-weighting filter: a high shelf modelling the
+// head followed by the RLB high-pass
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+// Build the K-weighting filter for any sample rate from its analog
+// prototype, matching the coefficients BS.1770 lists for 48 kHz
+func newKWeighting(sampleRate int) *kWeighting {
+	fs := float64(sampleRate)
+
+	const (
+		shelfFreq = 1681.974450955533
+		shelfGain = 3.999843853973347
+		shelfQ    = 0.7071752369554196
+		passFreq  = 38.13547087602444
+		passQ     = 0.5003270373238773
+	)
+
+	k := math.Tan(math.Pi * shelfFreq / fs)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * passFreq / fs)
+	a0 = 1 + k/passQ + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/passQ + k*k) / a0,
+	}
+
+	return &kWeighting{shelf: shelf, highPass: highPass}
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highPass.process(k.shelf.process(x))
+}
+
+// Post-process a finished capture of the given duration as configured.
+// With KeepOriginal the unprocessed audio is first copied to a memo of its
+// own, which is returned. Returns the new duration, which is unchanged if
+// nothing was done or processing failed.
+func postProcessCapture(capturePath string, duration float64, config Config) (float64, *Memo, error) {
+	if !config.NoiseReduction && !config.TrimSilence && !config.Normalize {
+		return duration, nil, nil
+	}
+
+	var original *Memo
+	if config.KeepOriginal {
+		memo, err := keepOriginal(capturePath, duration, config.MemosPath)
+		if err != nil {
+			return duration, nil, fmt.Errorf("keeping original: %w", err)
+		}
+		original = &memo
+	}
+
+	processed, err := processRecording(capturePath, config)
+	if err != nil {
+		return duration, original, err
+	}
+	return processed, original, nil
+}
+
+// Copy an unprocessed capture to a WAV memo in the memos directory
+func keepOriginal(capturePath string, duration float64, memosPath string) (Memo, error) {
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(capturePath), captureSuffix), FormatWAV.Extension())
+	dstPath := uniquePath(filepath.Join(memosPath, name+"_original"+FormatWAV.Extension()))
+
+	src, err := os.Open(capturePath)
+	if err != nil {
+		return Memo{}, err
+	}
+	defer src.Close()
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return Memo{}, err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dstPath)
+		return Memo{}, err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dstPath)
+		return Memo{}, err
+	}
+
+	info, err := os.Stat(dstPath)
+	if err != nil {
+		return Memo{}, err
+	}
+	return Memo{
+		ID:       newMemoID(),
+		Filename: filepath.Base(dstPath),
+		Name:     name + " (original)",
+		Duration: duration,
+		Created:  time.Now(),
+		Size:     info.Size(),
+		Tags:     []string{},
+		Format:   FormatWAV.String(),
+	}, nil
+}
+
+// Settings label for loudness normalization
+func normalizeLabel(config Config) string {
+	if !config.Normalize {
+		return "Off"
+	}
+	return fmt.Sprintf("%g LUFS", config.TargetLoudness)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// A 1 kHz tone reads as its RMS level in dBFS plus the K-weighting's
+// +0.7 dB at 1 kHz, less the 0.691 dB the loudness formula takes off, so
+// a full-scale sine reads -3.01 LUFS as BS.1770 specifies
+func TestIntegratedLoudness(t *testing.T) {
+	tests := []struct {
+		name      string
+		amplitude float64
+		channels  int
+		want      float64
+	}{
+		{name: "full scale", amplitude: 1, channels: 1, want: -3.01},
+		{name: "-20 dBFS", amplitude: 0.1, channels: 1, want: -23.01},
+		{name: "-20 dBFS stereo", amplitude: 0.1, channels: 2, want: -20.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := sineFloat(1000, 48000, tt.channels, 3)
+			for i := range samples {
+				samples[i] *= float32(tt.amplitude / 0.5)
+			}
+			if got := integratedLoudness(samples, tt.channels, 48000); math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("loudness = %.2f LUFS, want %.2f", got, tt.want)
+			}
+		})
+	}
+
+	if got := integratedLoudness(make([]float32, 48000), 1, 48000); !math.IsInf(got, -1) {
+		t.Errorf("loudness of silence = %v, want -Inf", got)
+	}
+	if got := integratedLoudness(sineFloat(1000, 48000, 1, 0.3), 1, 48000); !math.IsInf(got, -1) {
+		t.Errorf("loudness of audio shorter than a block = %v, want -Inf", got)
+	}
+}
+
+func TestNormalizeLoudness(t *testing.T) {
+	for _, target := range loudnessTargets {
+		samples := sineFloat(440, 44100, 1, 3)
+		for i := range samples {
+			samples[i] *= 0.1 // About -30 LUFS
+		}
+		normalizeLoudness(samples, integratedLoudness(samples, 1, 44100), target)
+		if got := integratedLoudness(samples, 1, 44100); math.Abs(got-target) > 0.5 {
+			t.Errorf("normalized to %.2f LUFS, want %.0f", got, target)
+		}
+	}
+}
+
+func TestNormalizeLoudnessCeiling(t *testing.T) {
+	samples := sineFloat(440, 44100, 1, 3)
+	for i := range samples {
+		samples[i] *= 0.1
+	}
+	samples[len(samples)/2] = 0.9 // A click the gain would push past full scale
+
+	gain := normalizeLoudness(samples, integratedLoudness(samples, 1, 44100), -14)
+	var peak float64
+	for _, v := range samples {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	if want := math.Pow(10, normalizeCeiling/20); math.Abs(peak-want) > 1e-4 {
+		t.Errorf("peak after normalizing = %.4f, want %.4f", peak, want)
+	}
+	if want := normalizeCeiling - 20*math.Log10(0.9); math.Abs(gain-want) > 1e-6 {
+		t.Errorf("gain = %.2f dB, want %.2f", gain, want)
+	}
+
+	silence := make([]float32, 44100)
+	if gain := normalizeLoudness(silence, math.Inf(-1), -16); gain != 0 {
+		t.Errorf("gain applied to silence = %v, want 0", gain)
+	}
+}
+
+func TestTrimSilence(t *testing.T) {
+	const sampleRate = 8000
+	padding := int(trimPadding.Seconds() * sampleRate)
+
+	// Silence, then sound from frame soundStart to soundEnd, then silence
+	clip := func(channels, frames, soundStart, soundEnd, loudChannel int) []float32 {
+		samples := make([]float32, frames*channels)
+		for i := soundStart; i < soundEnd; i++ {
+			v := float32(0.5)
+			if i%2 == 1 {
+				v = -0.5
+			}
+			samples[i*channels+loudChannel] = v
+		}
+		return samples
+	}
+
+	tests := []struct {
+		name       string
+		channels   int
+		samples    []float32
+		wantFrames int
+		wantStart  int // Frame of the input the output starts at
+	}{
+		{
+			name:       "silence on both sides",
+			channels:   1,
+			samples:    clip(1, 5*sampleRate, 2*sampleRate, 3*sampleRate, 0),
+			wantFrames: sampleRate + 2*padding,
+			wantStart:  2*sampleRate - padding,
+		},
+		{
+			name:       "sound in one channel",
+			channels:   2,
+			samples:    clip(2, 5*sampleRate, 2*sampleRate, 3*sampleRate, 1),
+			wantFrames: sampleRate + 2*padding,
+			wantStart:  2*sampleRate - padding,
+		},
+		{
+			name:       "sound near the edges",
+			channels:   1,
+			samples:    clip(1, 3*sampleRate, 100, 3*sampleRate-100, 0),
+			wantFrames: 3 * sampleRate,
+		},
+		{
+			name:       "silence only",
+			channels:   1,
+			samples:    make([]float32, 2*sampleRate),
+			wantFrames: 2 * sampleRate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimSilence(tt.samples, tt.channels, sampleRate, DefaultTrimThreshold)
+			if frames := len(got) / tt.channels; frames != tt.wantFrames {
+				t.Errorf("trimmed to %d frames, want %d", frames, tt.wantFrames)
+			}
+			if start := tt.samples[tt.wantStart*tt.channels:]; len(got) > 0 && &got[0] != &start[0] {
+				t.Errorf("trimmed audio does not start at frame %d", tt.wantStart)
+			}
+		})
+	}
+}
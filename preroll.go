@@ -0,0 +1,210 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Longest pre-roll accepted from the config, in seconds
+const MaxPreRollTime = 30.0
+
+// Pre-roll lengths offered in the settings, in seconds; 0 is off
+var preRollTimes = []float64{0, 1, 2, 3, 5, 10}
+
+// preRoll keeps the last seconds of input in memory while the memo list is
+// shown, so a recording starts with what was said just before it was
+// requested. Its input stream is handed over to the recording, which
+// writes the remembered audio and then everything the callback delivers
+// after it, without a gap.
+type preRoll struct {
+	config StreamConfig // Stream requested, to tell whether a recording can take it over
+	length float64      // Seconds remembered
+	stream AudioStream
+
+	ring    *ringBuffer // From the input callback to the keeper
+	buf     []float32   // Scratch for draining the ring
+	history []float32   // Circular buffer of the latest input
+	next    int         // Index in history the next sample goes to
+	full    bool        // Whether history has wrapped around
+
+	target    atomic.Pointer[AudioDevice] // Recording the callback feeds after the hand-over
+	handedOff atomic.Bool                 // Set by the first callback that fed the recording
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open and start an input stream that remembers the last seconds of input
+func startPreRoll(backend AudioBackend, config StreamConfig, seconds float64) (*preRoll, error) {
+	p := &preRoll{config: config, length: seconds, stop: make(chan struct{})}
+	stream, err := backend.OpenInputStream(config, p.process)
+	if err != nil {
+		return nil, err
+	}
+	p.stream = stream
+
+	samplesPerSecond := float64(stream.SampleRate() * stream.Channels())
+	p.ring = newRingBuffer(int(samplesPerSecond * captureBufferTime.Seconds()))
+	p.buf = make([]float32, len(p.ring.buf))
+	p.history = make([]float32, int(seconds*float64(stream.SampleRate()))*stream.Channels())
+
+	p.wg.Add(1)
+	go p.keep()
+
+	if err := stream.Start(); err != nil {
+		p.close()
+		return nil, err
+	}
+	log.Printf("Pre-roll listening (%gs, %d Hz, %d channels)", seconds, stream.SampleRate(), stream.Channels())
+	return p, nil
+}
+
+// Input callback: queue the samples for the keeper until the recording
+// takes over, then feed the recording
+func (p *preRoll) process(in []float32) {
+	if d := p.target.Load(); d != nil {
+		p.handedOff.Store(true)
+		d.processInput(in)
+		return
+	}
+	p.ring.push(in)
+}
+
+// Move queued input into the history until stopped
+func (p *preRoll) keep() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(writerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.drain()
+		}
+	}
+}
+
+// Move queued input into the history, overwriting the oldest samples
+func (p *preRoll) drain() {
+	if len(p.history) == 0 {
+		return
+	}
+	for {
+		n := p.ring.pop(p.buf)
+		if n == 0 {
+			return
+		}
+		for _, v := range p.buf[:n] {
+			p.history[p.next] = v
+			p.next++
+			if p.next == len(p.history) {
+				p.next = 0
+				p.full = true
+			}
+		}
+	}
+}
+
+// The remembered input, oldest first
+func (p *preRoll) recent() []float32 {
+	if !p.full {
+		return p.history[:p.next]
+	}
+	return append(append([]float32{}, p.history[p.next:]...), p.history[:p.next]...)
+}
+
+// Stop moving input into the history, leaving it to whoever takes over
+func (p *preRoll) stopKeeper() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+	p.stop = nil
+}
+
+// Stop listening and release the stream
+func (p *preRoll) close() {
+	p.stopKeeper()
+	if err := p.stream.Stop(); err != nil {
+		log.Printf("Error stopping pre-roll stream: %v", err)
+	}
+	if err := p.stream.Close(); err != nil {
+		log.Printf("Error closing pre-roll stream: %v", err)
+	}
+}
+
+// Write the pre-roll to the recording file once the input callback feeds
+// the capture ring, so no input is lost or written out of order between
+// the two. Called by the writer before anything else.
+func (d *AudioDevice) writePreRoll() {
+	p := d.preRoll
+	ticker := time.NewTicker(writerInterval)
+	defer ticker.Stop()
+	for !p.handedOff.Load() {
+		select {
+		case <-d.stopCapture:
+			// The stream has stopped, so nothing is left in flight
+			p.handedOff.Store(true)
+		case <-ticker.C:
+		}
+	}
+
+	p.drain()
+	d.resampleBuf = d.resampler.process(d.resampleBuf[:0], p.recent())
+	d.writeCapture(d.resampleBuf)
+}
+
+// Open or close the pre-roll stream as needed: it listens while the memo
+// list is shown and pre-roll is on, reopening when its settings change.
+// A stream that fails to open is not retried until the settings are left.
+func (m *Model) updatePreRoll() {
+	want := m.config.PreRollTime > 0 && m.state == StateViewing && !m.recording
+	if m.preRoll != nil && (!want || m.preRoll.config != m.inputStreamConfig() || m.preRoll.length != m.config.PreRollTime) {
+		m.preRoll.close()
+		m.preRoll = nil
+	}
+	if !want || m.preRoll != nil || m.preRollFailed {
+		return
+	}
+
+	m.initializeAudioDevices()
+	p, err := startPreRoll(m.backend, m.inputStreamConfig(), m.config.PreRollTime)
+	if err != nil {
+		log.Printf("Error opening pre-roll stream: %v", err)
+		m.preRollFailed = true
+		return
+	}
+	m.preRoll = p
+}
+
+// Take over the pre-roll stream for a recording if it was opened with the
+// stream config the recording needs; otherwise close it
+func (m *Model) takePreRoll() *preRoll {
+	p := m.preRoll
+	m.preRoll = nil
+	if p == nil {
+		return nil
+	}
+	if p.config != m.inputStreamConfig() {
+		p.close()
+		return nil
+	}
+	p.stopKeeper()
+	return p
+}
+
+// Stream config for recording from the selected input device (the backend
+// falls back to the default input device when the selection is unavailable)
+func (m Model) inputStreamConfig() StreamConfig {
+	return StreamConfig{
+		DeviceID:        m.config.InputDevice,
+		SampleRate:      m.config.SampleRate,
+		Channels:        m.config.ChannelCount,
+		FramesPerBuffer: 1024,
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Find audio files in the memos directory that have no metadata, such as
+// recordings interrupted by a crash. WAV headers left unfinalized are
+// repaired from the file size. Only headers are read, so the duration of
+// compressed files is left at zero until they are imported. The returned
+// memos describe the files but are not yet part of the memo list.
+func findOrphanedMemos(memosPath string, memos []Memo) []Memo {
+	entries, err := os.ReadDir(memosPath)
+	if err != nil {
+		log.Printf("Error scanning memos directory: %v", err)
+		return nil
+	}
+
+	known := make(map[string]bool, len(memos))
+	for _, memo := range memos {
+		known[memo.Filename] = true
+	}
+
+	var orphans []Memo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || known[name] {
+			continue
+		}
+
+		format, ok := formatForExtension(filepath.Ext(name))
+		if !ok {
+			continue
+		}
+
+		// An encoded file next to its capture was cut off mid-encode; the
+		// capture holds the complete recording
+		if !strings.HasSuffix(name, captureSuffix) && format != FormatWAV {
+			if _, err := os.Stat(capturePath(filepath.Join(memosPath, name))); err == nil {
+				continue
+			}
+		}
+
+		memo, err := orphanedMemo(filepath.Join(memosPath, name), format)
+		if err != nil {
+			log.Printf("Skipping unreadable orphaned file %s: %v", name, err)
+			continue
+		}
+		orphans = append(orphans, memo)
+	}
+
+	if len(orphans) > 0 {
+		log.Printf("Found %d orphaned memo file(s) in %s", len(orphans), memosPath)
+	}
+	return orphans
+}
+
+// Repair an orphaned file if needed and describe it as a memo
+func orphanedMemo(filePath string, format AudioFormat) (Memo, error) {
+	var duration float64
+	if format == FormatWAV {
+		repaired, err := repairWAVHeader(filePath)
+		if err != nil {
+			return Memo{}, err
+		}
+		if repaired {
+			log.Printf("Repaired WAV header of %s", filePath)
+		}
+
+		wav, err := readWAVInfo(filePath)
+		if err != nil {
+			return Memo{}, err
+		}
+		duration = float64(wav.DataSize/int64(wav.BlockAlign)) / float64(wav.SampleRate)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return Memo{}, err
+	}
+
+	filename := filepath.Base(filePath)
+	name := strings.TrimSuffix(strings.TrimSuffix(filename, captureSuffix), filepath.Ext(filename))
+	return Memo{
+		ID:       newMemoID(),
+		Filename: filename,
+		Name:     name,
+		Duration: duration,
+		Created:  info.ModTime(),
+		Size:     info.Size(),
+		Tags:     []string{},
+		Format:   format.String(),
+	}, nil
+}
+
+// Duration in seconds of any supported audio file, by decoding it
+func decodedDuration(filePath string) (float64, error) {
+	samples, sampleRate, channels, err := readAudioData(filePath)
+	if err != nil {
+		return 0, err
+	}
+	if sampleRate <= 0 || channels <= 0 {
+		return 0, nil
+	}
+	return float64(len(samples)/channels) / float64(sampleRate), nil
+}
+
+// Add the orphaned memos found at startup to the memo list. Captures of
+// compressed memos are kept as WAV memos rather than encoded. Files that
+// can't be decoded or renamed stay where they are and are offered again
+// next time.
+func (m *Model) importOrphanedMemos() {
+	var imported []Memo
+	for _, memo := range m.orphanedMemos {
+		filePath := filepath.Join(m.config.MemosPath, memo.Filename)
+		if memo.Format != FormatWAV.String() {
+			duration, err := decodedDuration(filePath)
+			if err != nil {
+				log.Printf("Error reading orphaned file %s: %v", memo.Filename, err)
+				continue
+			}
+			memo.Duration = duration
+		}
+		if strings.HasSuffix(memo.Filename, captureSuffix) {
+			wavName := strings.TrimSuffix(memo.Filename, captureSuffix) + FormatWAV.Extension()
+			if err := os.Rename(filePath, filepath.Join(m.config.MemosPath, wavName)); err != nil {
+				log.Printf("Error renaming capture %s: %v", memo.Filename, err)
+				continue
+			}
+			memo.Filename = wavName
+		}
+		imported = append(imported, memo)
+	}
+
+	found := len(m.orphanedMemos)
+	m.orphanedMemos = nil
+	if err := m.store.Update(imported, nil); err != nil {
+		log.Printf("Error saving recovered memos: %v", err)
+		m.showNotification(fmt.Sprintf("Recovering memos failed: %v", err))
+		return
+	}
+
+	m.memos = append(m.memos, imported...)
+	sortMemos(m.memos)
+	m.memoList.SetItems(convertMemosToListItems(m.memos))
+
+	if len(imported) < found {
+		m.showNotification(fmt.Sprintf("Recovered %d of %d memo(s), see log", len(imported), found))
+	} else {
+		m.showNotification(fmt.Sprintf("Recovered %d memo(s)", len(imported)))
+	}
+}
@@ -94,7 +94,7 @@ try {
         
         $env:GOARCH = $target.Arch
         
-        $buildCmd = "go build -ldflags=`"-X main.version=$Version`" -o `"dist/$($target.Name)`" main.go"
+        $buildCmd = "go build -ldflags=`"-X main.version=$Version`" -o `"dist/$($target.Name)`" ."
         
         Invoke-Expression $buildCmd
         if ($LASTEXITCODE -ne 0) {
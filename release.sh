@@ -109,7 +109,7 @@ main() {
         
         export GOARCH="$arch"
         
-        if go build -ldflags="-X main.version=$VERSION" -o "dist/$binary_name" main.go; then
+        if go build -ldflags="-X main.version=$VERSION" -o "dist/$binary_name" .; then
             # Verify binary was created
             if [ -f "dist/$binary_name" ]; then
                 size=$(du -h "dist/$binary_name" | cut -f1)
@@ -0,0 +1,168 @@
+package main
+
+import "math"
+
+// Resampler filter parameters
+const (
+	resampleZeroCrossings = 16  // Kernel half-width in zero crossings of the sinc
+	resampleTableRes      = 256 // Kernel table entries per zero crossing
+	resampleRolloff       = 0.95
+)
+
+// resampler converts interleaved float32 audio between sample rates with a
+// windowed-sinc filter. It is streaming: input may arrive in chunks of any
+// size, as delivered by an audio callback.
+type resampler struct {
+	inRate   int
+	outRate  int
+	channels int
+
+	step      float64   // Input frames advanced per output frame
+	scale     float64   // Kernel time scale, below 1 when downsampling
+	halfWidth float64   // Kernel half-width in input frames
+	kernel    []float64 // Kernel by distance, resampleTableRes per zero crossing
+
+	history []float32 // Interleaved input frames still needed by the filter
+	pos     float64   // Position of the next output frame within history
+	inTotal int64     // Input frames received
+	outDone int64     // Output frames produced
+}
+
+func newResampler(inRate, outRate, channels int) *resampler {
+	r := &resampler{
+		inRate:   inRate,
+		outRate:  outRate,
+		channels: channels,
+		step:     float64(inRate) / float64(outRate),
+	}
+	if inRate == outRate {
+		return r
+	}
+
+	// Lower the cutoff below the output Nyquist rate when downsampling
+	r.scale = math.Min(1, float64(outRate)/float64(inRate)) * resampleRolloff
+	r.halfWidth = resampleZeroCrossings / r.scale
+
+	// Blackman-windowed sinc, tabulated over one side
+	r.kernel = make([]float64, resampleZeroCrossings*resampleTableRes+2)
+	for i := range r.kernel {
+		x := float64(i) / resampleTableRes
+		if x >= resampleZeroCrossings {
+			break
+		}
+		sinc := 1.0
+		if x > 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		w := 0.42 + 0.5*math.Cos(math.Pi*x/resampleZeroCrossings) + 0.08*math.Cos(2*math.Pi*x/resampleZeroCrossings)
+		r.kernel[i] = sinc * w * r.scale
+	}
+
+	// Start with silence before the first frame so the filter is centred
+	// on it without dropping any input
+	pad := int(math.Ceil(r.halfWidth))
+	r.history = make([]float32, pad*channels)
+	r.pos = float64(pad)
+	return r
+}
+
+// Whether the resampler passes audio through unchanged
+func (r *resampler) passthrough() bool {
+	return r.inRate == r.outRate
+}
+
+// Convert a chunk of input and append the output frames ready so far to out
+func (r *resampler) process(out, in []float32) []float32 {
+	r.inTotal += int64(len(in) / r.channels)
+	if r.passthrough() {
+		r.outDone += int64(len(in) / r.channels)
+		return append(out, in...)
+	}
+
+	r.history = append(r.history, in...)
+	return r.drain(out, r.expected())
+}
+
+// Convert the input still held back by the filter and append it to out.
+// The resampler can not be used afterwards.
+func (r *resampler) flush(out []float32) []float32 {
+	if r.passthrough() {
+		return out
+	}
+	pad := int(math.Ceil(r.halfWidth)) + 1
+	r.history = append(r.history, make([]float32, pad*r.channels)...)
+	return r.drain(out, r.expected())
+}
+
+// Output frames the input received so far corresponds to
+func (r *resampler) expected() int64 {
+	return int64(math.Round(float64(r.inTotal) * float64(r.outRate) / float64(r.inRate)))
+}
+
+// Produce output frames while the history covers the whole kernel, up to limit
+func (r *resampler) drain(out []float32, limit int64) []float32 {
+	frames := len(r.history) / r.channels
+	for r.outDone < limit && r.pos+r.halfWidth < float64(frames) {
+		first := int(math.Ceil(r.pos - r.halfWidth))
+		last := int(math.Floor(r.pos + r.halfWidth))
+		for ch := 0; ch < r.channels; ch++ {
+			var sum float64
+			for k := first; k <= last; k++ {
+				sum += float64(r.history[k*r.channels+ch]) * r.tap(r.pos-float64(k))
+			}
+			out = append(out, float32(sum))
+		}
+		r.pos += r.step
+		r.outDone++
+	}
+
+	// Drop frames the kernel has moved past
+	if drop := int(math.Ceil(r.pos-r.halfWidth)) - 1; drop > 0 {
+		drop = min(drop, frames)
+		r.history = r.history[:copy(r.history, r.history[drop*r.channels:])]
+		r.pos -= float64(drop)
+	}
+	return out
+}
+
+// Kernel value at a distance in input frames, interpolated from the table
+func (r *resampler) tap(distance float64) float64 {
+	x := math.Abs(distance) * r.scale * resampleTableRes
+	i := int(x)
+	if i >= len(r.kernel)-1 {
+		return 0
+	}
+	frac := x - float64(i)
+	return r.kernel[i] + (r.kernel[i+1]-r.kernel[i])*frac
+}
+
+// Resample a whole interleaved clip, e.g. when joining memos recorded at
+// different rates
+func resampleFloat(samples []float32, channels, inRate, outRate int) []float32 {
+	if inRate == outRate || channels <= 0 {
+		return samples
+	}
+	r := newResampler(inRate, outRate, channels)
+	out := r.process(nil, samples)
+	return r.flush(out)
+}
+
+// Resample a whole interleaved 16-bit clip, e.g. for playback on a device
+// running at a different rate
+func resampleInt16(samples []int16, channels, inRate, outRate int) []int16 {
+	if inRate == outRate || channels <= 0 {
+		return samples
+	}
+
+	in := make([]float32, len(samples))
+	for i, s := range samples {
+		in[i] = float32(s) / 32768
+	}
+	out := resampleFloat(in, channels, inRate, outRate)
+
+	result := make([]int16, len(out))
+	for i, v := range out {
+		result[i] = floatToInt16(float64(v))
+	}
+	return result
+}
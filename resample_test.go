@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// An interleaved sine of amplitude 0.5 in [-1, 1]
+func sineFloat(freq float64, sampleRate, channels int, seconds float64) []float32 {
+	frames := int(seconds * float64(sampleRate))
+	samples := make([]float32, frames*channels)
+	for i := 0; i < frames; i++ {
+		v := float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+		for ch := 0; ch < channels; ch++ {
+			samples[i*channels+ch] = v
+		}
+	}
+	return samples
+}
+
+// Frequency of a periodic signal in one channel, from the sign changes
+// between its first and last upward zero crossing
+func zeroCrossingFrequency(samples []float32, channels, channel, sampleRate int) float64 {
+	first, last, cycles := -1, -1, 0
+	for i := 1; i < len(samples)/channels; i++ {
+		if samples[(i-1)*channels+channel] < 0 && samples[i*channels+channel] >= 0 {
+			if first < 0 {
+				first = i
+			} else {
+				cycles++
+			}
+			last = i
+		}
+	}
+	if cycles == 0 {
+		return 0
+	}
+	return float64(cycles) * float64(sampleRate) / float64(last-first)
+}
+
+func TestResampleFloat(t *testing.T) {
+	tests := []struct {
+		name            string
+		inRate, outRate int
+		channels        int
+		freq            float64
+	}{
+		{name: "48 kHz to 16 kHz", inRate: 48000, outRate: 16000, channels: 1, freq: 1000},
+		{name: "44.1 kHz to 48 kHz stereo", inRate: 44100, outRate: 48000, channels: 2, freq: 440},
+		{name: "16 kHz to 44.1 kHz", inRate: 16000, outRate: 44100, channels: 1, freq: 3000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := sineFloat(tt.freq, tt.inRate, tt.channels, 1)
+			out := resampleFloat(in, tt.channels, tt.inRate, tt.outRate)
+
+			if got, want := len(out)/tt.channels, tt.outRate; got != want {
+				t.Errorf("resampled to %d frames, want %d", got, want)
+			}
+			for ch := 0; ch < tt.channels; ch++ {
+				if got := zeroCrossingFrequency(out, tt.channels, ch, tt.outRate); math.Abs(got-tt.freq) > 1 {
+					t.Errorf("channel %d frequency = %.1f Hz, want %.0f Hz", ch, got, tt.freq)
+				}
+			}
+
+			// Away from the edges the amplitude is kept
+			var peak float64
+			for _, v := range out[len(out)/4 : len(out)*3/4] {
+				peak = math.Max(peak, math.Abs(float64(v)))
+			}
+			if math.Abs(peak-0.5) > 0.01 {
+				t.Errorf("peak = %.3f, want 0.5", peak)
+			}
+		})
+	}
+}
+
+// Downsampling filters out frequencies above the new Nyquist rate rather
+// than folding them back into the audible range
+func TestResampleAntiAliasing(t *testing.T) {
+	out := resampleFloat(sineFloat(10000, 48000, 1, 1), 1, 48000, 16000)
+
+	var sum float64
+	middle := out[len(out)/4 : len(out)*3/4]
+	for _, v := range middle {
+		sum += float64(v) * float64(v)
+	}
+	if rms := math.Sqrt(sum / float64(len(middle))); rms > 0.005 {
+		t.Errorf("RMS of a 10 kHz tone resampled to 16 kHz = %.4f, want below 0.005", rms)
+	}
+}
+
+// Resampling in chunks, as the recorder does, gives the same output as
+// resampling the whole clip at once
+func TestResamplerChunks(t *testing.T) {
+	in := sineFloat(1000, 48000, 2, 0.5)
+	whole := resampleFloat(in, 2, 48000, 16000)
+
+	r := newResampler(48000, 16000, 2)
+	var chunked []float32
+	for start, size := 0, 1; start < len(in); size = size*3 + 2 {
+		end := min(len(in), start+size*2)
+		chunked = r.process(chunked, in[start:end])
+		start = end
+	}
+	chunked = r.flush(chunked)
+
+	if len(chunked) != len(whole) {
+		t.Fatalf("chunked output has %d samples, want %d", len(chunked), len(whole))
+	}
+	for i := range whole {
+		if math.Abs(float64(chunked[i]-whole[i])) > 1e-6 {
+			t.Fatalf("sample %d = %v, want %v", i, chunked[i], whole[i])
+		}
+	}
+}
+
+func TestResampleInt16(t *testing.T) {
+	in := sineInt16(1000, 48000, 1, 1)
+	out := resampleInt16(in, 1, 48000, 16000)
+	if len(out) != 16000 {
+		t.Errorf("resampled to %d samples, want 16000", len(out))
+	}
+
+	if same := resampleInt16(in, 1, 48000, 48000); &same[0] != &in[0] {
+		t.Error("resampling to the same rate copied the samples")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "sync/atomic"
+
+// ringBuffer is a single-producer, single-consumer queue of samples. The
+// producer (the audio callback) and the consumer may run concurrently
+// without locks: each side only advances its own position.
+type ringBuffer struct {
+	buf  []float32
+	mask uint64
+
+	written atomic.Uint64 // Total samples pushed, advanced by the producer
+	read    atomic.Uint64 // Total samples popped, advanced by the consumer
+	dropped atomic.Uint64 // Samples discarded because the buffer was full
+}
+
+// Create a ring buffer holding at least capacity samples
+func newRingBuffer(capacity int) *ringBuffer {
+	size := 1
+	for size < capacity {
+		size <<= 1
+	}
+	return &ringBuffer{buf: make([]float32, size), mask: uint64(size - 1)}
+}
+
+// Append samples, or drop all of them if they do not fit so interleaved
+// frames stay aligned. Never blocks or allocates, so it is safe to call
+// from a real-time callback.
+func (r *ringBuffer) push(samples []float32) {
+	w := r.written.Load()
+	free := uint64(len(r.buf)) - (w - r.read.Load())
+	n := uint64(len(samples))
+	if n > free {
+		r.dropped.Add(n)
+		return
+	}
+
+	start := w & r.mask
+	copied := uint64(copy(r.buf[start:], samples[:n]))
+	copy(r.buf, samples[copied:n])
+	r.written.Store(w + n)
+}
+
+// Move up to len(dst) samples into dst and return how many were moved
+func (r *ringBuffer) pop(dst []float32) int {
+	rd := r.read.Load()
+	n := min(len(dst), int(r.written.Load()-rd))
+
+	start := rd & r.mask
+	copied := copy(dst[:n], r.buf[start:])
+	copy(dst[copied:n], r.buf)
+	r.read.Store(rd + uint64(n))
+	return n
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestRingBufferCapacity(t *testing.T) {
+	for _, tt := range []struct{ capacity, want int }{{1, 1}, {5, 8}, {8, 8}, {1000, 1024}} {
+		if got := len(newRingBuffer(tt.capacity).buf); got != tt.want {
+			t.Errorf("newRingBuffer(%d) holds %d samples, want %d", tt.capacity, got, tt.want)
+		}
+	}
+}
+
+func TestRingBufferWraparound(t *testing.T) {
+	r := newRingBuffer(8)
+	dst := make([]float32, 8)
+
+	// Move the positions near the end of buf so the next push wraps
+	r.push([]float32{1, 2, 3, 4, 5, 6})
+	if n := r.pop(dst); n != 6 {
+		t.Fatalf("pop = %d samples, want 6", n)
+	}
+
+	r.push([]float32{7, 8, 9, 10, 11})
+	if got := r.buf[:3]; !reflect.DeepEqual(got, []float32{9, 10, 11}) {
+		t.Errorf("buf after wrapping push starts with %v, want [9 10 11]", got)
+	}
+
+	// Pop across the end of buf in two reads
+	if n := r.pop(dst[:3]); n != 3 || !reflect.DeepEqual(dst[:3], []float32{7, 8, 9}) {
+		t.Errorf("pop = %v (%d samples), want [7 8 9]", dst[:n], n)
+	}
+	if n := r.pop(dst); n != 2 || !reflect.DeepEqual(dst[:2], []float32{10, 11}) {
+		t.Errorf("pop = %v (%d samples), want [10 11]", dst[:n], n)
+	}
+	if n := r.pop(dst); n != 0 {
+		t.Errorf("pop from empty buffer = %d samples, want 0", n)
+	}
+	if d := r.dropped.Load(); d != 0 {
+		t.Errorf("dropped = %d, want 0", d)
+	}
+}
+
+func TestRingBufferDropsWholePushWhenFull(t *testing.T) {
+	r := newRingBuffer(8)
+	r.push([]float32{1, 2, 3, 4, 5, 6})
+
+	// Three samples do not fit in the two free, so none are kept
+	r.push([]float32{7, 8, 9})
+	if d := r.dropped.Load(); d != 3 {
+		t.Errorf("dropped = %d, want 3", d)
+	}
+
+	// Exactly filling the buffer is fine
+	r.push([]float32{10, 11})
+	if d := r.dropped.Load(); d != 3 {
+		t.Errorf("dropped after filling = %d, want 3", d)
+	}
+	r.push([]float32{12})
+	if d := r.dropped.Load(); d != 4 {
+		t.Errorf("dropped after push to full buffer = %d, want 4", d)
+	}
+
+	dst := make([]float32, 16)
+	n := r.pop(dst)
+	if want := []float32{1, 2, 3, 4, 5, 6, 10, 11}; !reflect.DeepEqual(dst[:n], want) {
+		t.Errorf("pop = %v, want %v", dst[:n], want)
+	}
+}
+
+// One goroutine pushes a counting sequence in odd-sized blocks while
+// another pops; run with -race to check the positions are published
+// safely. Dropped blocks are pushed again, so every sample must arrive
+// in order.
+func TestRingBufferConcurrent(t *testing.T) {
+	const total = 50000
+	r := newRingBuffer(256)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		block := make([]float32, 0, 37)
+		for next := 0; next < total; {
+			block = block[:0]
+			for len(block) < cap(block) && next < total {
+				block = append(block, float32(next))
+				next++
+			}
+			before := r.dropped.Load()
+			r.push(block)
+			if r.dropped.Load() != before {
+				// Retry dropped blocks so the consumer sees every sample
+				next -= len(block)
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	dst := make([]float32, 53)
+	for want := 0; want < total; {
+		n := r.pop(dst)
+		if n == 0 {
+			runtime.Gosched()
+		}
+		for _, sample := range dst[:n] {
+			if sample != float32(want) {
+				t.Fatalf("popped %v, want %d", sample, want)
+			}
+			want++
+		}
+	}
+	wg.Wait()
+
+	if n := r.pop(dst); n != 0 {
+		t.Errorf("pop after all samples = %d samples, want 0", n)
+	}
+}
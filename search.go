@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Where a piece of searchable text comes from
+type searchField int
+
+const (
+	fieldName searchField = iota
+	fieldTag
+	fieldTranscript
+)
+
+// Search tuning
+const (
+	maxSearchTerms   = 16  // Query words beyond this are ignored
+	prefixMatchScore = 0.5 // Weight of a word that only starts with a query word
+	cueWords         = 12  // Words of a JSON transcript grouped into one searchable cue
+)
+
+// Ranking weight of a match in each field: a word in the name says more
+// about a memo than one somewhere in what was said
+var fieldWeights = [...]float64{fieldName: 3, fieldTag: 2, fieldTranscript: 1}
+
+// searchDoc is one piece of searchable text of a memo: its name, a tag,
+// or a transcript cue
+type searchDoc struct {
+	memo  int // Index into the memos the index was built from
+	field searchField
+	text  string
+	at    time.Duration // Where a transcript cue is spoken
+	timed bool          // Whether at is known
+}
+
+// searchIndex is an inverted index from words to the texts containing them
+type searchIndex struct {
+	memos    []Memo
+	docs     []searchDoc
+	postings map[string][]int // Word to the docs containing it, ascending
+	words    []string         // Indexed words, sorted for prefix lookups
+}
+
+// searchResult is a memo matching a query and its best matching text
+type searchResult struct {
+	memo  Memo
+	score float64
+	hit   searchDoc
+}
+
+// Index the names and tags of memos and the transcripts stored next to
+// their audio: .txt and .srt files and the transcripts voicelog makes
+func buildSearchIndex(memosPath string, memos []Memo) *searchIndex {
+	idx := &searchIndex{memos: memos, postings: make(map[string][]int)}
+	for i, memo := range memos {
+		idx.add(searchDoc{memo: i, field: fieldName, text: memo.Name})
+		for _, tag := range memo.Tags {
+			idx.add(searchDoc{memo: i, field: fieldTag, text: tag})
+		}
+		for _, cue := range loadTranscriptCues(memosPath, memo.Filename) {
+			cue.memo = i
+			idx.add(cue)
+		}
+	}
+
+	for word := range idx.postings {
+		idx.words = append(idx.words, word)
+	}
+	sort.Strings(idx.words)
+	return idx
+}
+
+func (idx *searchIndex) add(doc searchDoc) {
+	id := len(idx.docs)
+	idx.docs = append(idx.docs, doc)
+	for _, word := range searchWords(doc.text) {
+		postings := idx.postings[word]
+		if len(postings) == 0 || postings[len(postings)-1] != id {
+			idx.postings[word] = append(postings, id)
+		}
+	}
+}
+
+// Find the memos containing every word of the query, as a whole word or
+// the start of one, best first. Each query word scores by the rarity of
+// the words it matched among memos, the weight of the fields they are in
+// and how often they occur there.
+func (idx *searchIndex) search(query string) []searchResult {
+	terms := searchWords(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	terms = terms[:min(len(terms), maxSearchTerms)]
+
+	scores := make(map[int]float64)
+	matched := make(map[int]int)     // Query words each memo matched
+	docTerms := make(map[int]uint64) // Query words each doc matched, as bits
+	for t, term := range terms {
+		termScores := make(map[int]float64)
+		for _, word := range idx.wordsWithPrefix(term) {
+			weight := prefixMatchScore
+			if word == term {
+				weight = 1
+			}
+
+			postings := idx.postings[word]
+			memos := make(map[int]bool)
+			counts := make(map[int][len(fieldWeights)]int)
+			for _, id := range postings {
+				doc := idx.docs[id]
+				memos[doc.memo] = true
+				c := counts[doc.memo]
+				c[doc.field]++
+				counts[doc.memo] = c
+				docTerms[id] |= 1 << t
+			}
+
+			idf := math.Log(1 + float64(len(idx.memos))/float64(len(memos)))
+			for memo, c := range counts {
+				for field, n := range c {
+					if n > 0 {
+						termScores[memo] += weight * idf * fieldWeights[field] * (1 + math.Log(float64(n)))
+					}
+				}
+			}
+		}
+		for memo, score := range termScores {
+			scores[memo] += score
+			matched[memo]++
+		}
+	}
+
+	var results []searchResult
+	for memo, score := range scores {
+		if matched[memo] == len(terms) {
+			results = append(results, searchResult{memo: idx.memos[memo], score: score})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].memo.Created.After(results[j].memo.Created)
+	})
+
+	// Show each memo with the text matching the most query words,
+	// preferring a timed cue to jump to, then the earliest
+	best := make(map[string]int)
+	for id := range docTerms {
+		filename := idx.memos[idx.docs[id].memo].Filename
+		if current, ok := best[filename]; !ok || idx.betterHit(id, current, docTerms) {
+			best[filename] = id
+		}
+	}
+	for i := range results {
+		results[i].hit = idx.docs[best[results[i].memo.Filename]]
+	}
+	return results
+}
+
+// Whether doc a makes a better hit to show than doc b, given the query
+// words each doc matched as bits
+func (idx *searchIndex) betterHit(a, b int, docTerms map[int]uint64) bool {
+	aCount, bCount := bits.OnesCount64(docTerms[a]), bits.OnesCount64(docTerms[b])
+	docA, docB := idx.docs[a], idx.docs[b]
+	switch {
+	case aCount != bCount:
+		return aCount > bCount
+	case docA.timed != docB.timed:
+		return docA.timed
+	case docA.timed && docA.at != docB.at:
+		return docA.at < docB.at
+	}
+	return a < b
+}
+
+// Indexed words starting with prefix, including prefix itself
+func (idx *searchIndex) wordsWithPrefix(prefix string) []string {
+	var words []string
+	for i := sort.SearchStrings(idx.words, prefix); i < len(idx.words) && strings.HasPrefix(idx.words[i], prefix); i++ {
+		words = append(words, idx.words[i])
+	}
+	return words
+}
+
+// Split text into lowercase words of letters and digits
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Read the transcripts of a memo into cues: lines of a .txt file, cues of
+// a .srt file and runs of words of a voicelog transcript. Missing or
+// unreadable files add nothing.
+func loadTranscriptCues(memosPath, filename string) []searchDoc {
+	base := filepath.Join(memosPath, strings.TrimSuffix(filename, filepath.Ext(filename)))
+	var cues []searchDoc
+
+	if data, err := os.ReadFile(base + ".txt"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				cues = append(cues, searchDoc{field: fieldTranscript, text: line})
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(base + ".srt"); err == nil {
+		cues = append(cues, parseSRT(string(data))...)
+	}
+
+	if transcript, err := loadTranscript(memosPath, filename); err == nil {
+		for start := 0; start < len(transcript.Words); {
+			end := start + 1
+			for end < len(transcript.Words) && end-start < cueWords && !strings.ContainsAny(transcript.Words[end-1].Text, ".?!") {
+				end++
+			}
+			var text []string
+			for _, w := range transcript.Words[start:end] {
+				text = append(text, w.Text)
+			}
+			cues = append(cues, searchDoc{
+				field: fieldTranscript,
+				text:  strings.Join(text, " "),
+				at:    time.Duration(transcript.Words[start].Start * float64(time.Second)),
+				timed: true,
+			})
+			start = end
+		}
+	}
+
+	return cues
+}
+
+// SRT cue timing line, e.g. "00:01:02,500 --> 00:01:05,000"
+var srtTiming = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})[,.](\d{1,3})\s*-->`)
+
+// Parse SubRip subtitles into timed cues. Each cue is a block of lines
+// separated by blank lines: an optional number, the timing and the text.
+func parseSRT(data string) []searchDoc {
+	data = strings.TrimPrefix(strings.ReplaceAll(data, "\r\n", "\n"), "\ufeff")
+
+	var cues []searchDoc
+	for _, block := range strings.Split(data, "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		for i, line := range lines {
+			match := srtTiming.FindStringSubmatch(strings.TrimSpace(line))
+			if match == nil {
+				continue
+			}
+			text := strings.TrimSpace(strings.Join(lines[i+1:], " "))
+			if text == "" {
+				break
+			}
+
+			var parts [4]int
+			for j := range parts {
+				parts[j], _ = strconv.Atoi(match[j+1])
+			}
+			millis, _ := strconv.Atoi((match[4] + "00")[:3])
+			at := time.Duration(parts[0])*time.Hour + time.Duration(parts[1])*time.Minute +
+				time.Duration(parts[2])*time.Second + time.Duration(millis)*time.Millisecond
+			cues = append(cues, searchDoc{field: fieldTranscript, text: text, at: at, timed: true})
+			break
+		}
+	}
+	return cues
+}
+
+// Enter search mode with a fresh index of the memos
+func (m *Model) startSearch() {
+	m.searchIndex = buildSearchIndex(m.config.MemosPath, m.memos)
+	m.searchResults = nil
+	m.searchSelected = 0
+	m.state = StateSearching
+	m.textInput.Reset()
+	m.textInput.Placeholder = "Search names, tags and transcripts..."
+	m.textInput.Focus()
+}
+
+// Run the query typed so far
+func (m *Model) updateSearch() {
+	m.searchResults = m.searchIndex.search(m.textInput.Value())
+	m.searchSelected = max(0, min(m.searchSelected, len(m.searchResults)-1))
+}
+
+// Leave search mode, selecting the chosen result's memo and, when the hit
+// is a timed transcript cue, playing it from there
+func (m *Model) openSearchResult() {
+	m.endSearch()
+	if m.searchSelected >= len(m.searchResults) {
+		return
+	}
+	result := m.searchResults[m.searchSelected]
+
+	for i, memo := range m.memos {
+		if memo.Filename != result.memo.Filename {
+			continue
+		}
+		m.selectedIdx = i
+		m.memoList.Select(i)
+		if result.hit.timed {
+			if m.playing || m.playbackPaused() {
+				m.stopPlayback()
+			}
+			m.startPlayback()
+			m.seekPlaybackTo(result.hit.at)
+		}
+		return
+	}
+}
+
+// Leave search mode
+func (m *Model) endSearch() {
+	m.state = StateViewing
+	m.textInput.Reset()
+	m.textInput.Placeholder = "Enter memo name..."
+}
+
+// Render the search results, as many as fit in height lines, with the
+// words matching the query highlighted
+func (m Model) renderSearchResults(width, height int) string {
+	query := searchWords(m.textInput.Value())
+	var lines []string
+	switch {
+	case len(query) == 0:
+		lines = append(lines, mutedStyle.Render("Type to search"))
+	case len(m.searchResults) == 0:
+		lines = append(lines, mutedStyle.Render("No matches"))
+	case len(m.searchResults) == 1:
+		lines = append(lines, mutedStyle.Render("1 match"))
+	default:
+		lines = append(lines, mutedStyle.Render(fmt.Sprintf("%d matches", len(m.searchResults))))
+	}
+	lines = append(lines, "")
+
+	// Two lines per result and a blank line between results
+	count := max(1, (height-len(lines)+1)/3)
+	first := max(0, min(m.searchSelected-count/2, len(m.searchResults)-count))
+	for i := first; i < min(len(m.searchResults), first+count); i++ {
+		result := m.searchResults[i]
+		marker, style := "  ", normalStyle
+		if i == m.searchSelected {
+			marker, style = selectedStyle.Render("▶ "), selectedStyle
+		}
+
+		var source string
+		switch {
+		case result.hit.field == fieldTag:
+			source = "tag"
+		case result.hit.timed:
+			source = "at " + formatDuration(result.hit.at)
+		case result.hit.field == fieldTranscript:
+			source = "transcript"
+		}
+		name := highlightMatches(truncateText(result.memo.Name, max(10, width-lipgloss.Width(source)-4)), query, style)
+		gap := max(1, width-2-lipgloss.Width(name)-lipgloss.Width(source))
+		lines = append(lines, marker+name+strings.Repeat(" ", gap)+mutedStyle.Render(source))
+
+		snippet := ""
+		if result.hit.field != fieldName {
+			snippet = highlightMatches(searchSnippet(result.hit.text, query, width-2), query, mutedStyle)
+		}
+		lines = append(lines, "  "+snippet)
+		if i < first+count-1 {
+			lines = append(lines, "")
+		}
+	}
+
+	return memoListBorderStyle.Width(width + 4).Height(height + 2).Render(strings.Join(lines, "\n"))
+}
+
+// Cut text down to width around its first word matching the query
+func searchSnippet(text string, query []string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+
+	start := 0
+	for i := 0; i < len(runes); {
+		end := i
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+			end++
+		}
+		if end > i && matchesQuery(string(runes[i:end]), query) {
+			start = i
+			break
+		}
+		i = end + 1
+	}
+
+	// Keep some context before the match, starting at a word
+	start = max(0, start-width/3)
+	for start > 0 && start < len(runes) && runes[start-1] != ' ' {
+		start++
+	}
+	snippet := string(runes[start:])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	return truncateText(snippet, width)
+}
+
+// Render text in style with the words matching the query highlighted
+func highlightMatches(text string, query []string, style lipgloss.Style) string {
+	highlight := style.Foreground(lipgloss.Color(AccentOrange)).Bold(true)
+
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		end := i
+		word := unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) == word {
+			end++
+		}
+		if word && matchesQuery(string(runes[i:end]), query) {
+			b.WriteString(highlight.Render(string(runes[i:end])))
+		} else {
+			b.WriteString(style.Render(string(runes[i:end])))
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// Whether a word matches or starts with a query word
+func matchesQuery(word string, query []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range query {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle keys in search mode: typing refines the query, the arrows pick a
+// result, enter opens it and escape leaves
+func (m Model) handleSearchKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch {
+	case key.Matches(msg, keys.Enter):
+		m.openSearchResult()
+
+	case key.Matches(msg, keys.Escape):
+		m.endSearch()
+
+	case key.Matches(msg, keys.PrevResult):
+		m.searchSelected = max(0, m.searchSelected-1)
+
+	case key.Matches(msg, keys.NextResult):
+		m.searchSelected = max(0, min(m.searchSelected+1, len(m.searchResults)-1))
+
+	default:
+		m.textInput, cmd = m.textInput.Update(msg)
+		m.updateSearch()
+	}
+
+	return m, cmd
+}
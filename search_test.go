@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSRT(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []searchDoc
+	}{
+		{
+			name: "canonical",
+			data: "1\n00:00:01,000 --> 00:00:02,000\nHello there\n\n2\n01:02:03,450 --> 01:02:05,000\nSecond cue\n",
+			want: []searchDoc{
+				{field: fieldTranscript, text: "Hello there", at: time.Second, timed: true},
+				{field: fieldTranscript, text: "Second cue", at: time.Hour + 2*time.Minute + 3450*time.Millisecond, timed: true},
+			},
+		},
+		{
+			name: "CRLF and BOM",
+			data: "\ufeff1\r\n00:00:01,000 --> 00:00:02,000\r\nHello there\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nSecond cue\r\n",
+			want: []searchDoc{
+				{field: fieldTranscript, text: "Hello there", at: time.Second, timed: true},
+				{field: fieldTranscript, text: "Second cue", at: 3 * time.Second, timed: true},
+			},
+		},
+		{
+			name: "missing cue numbers",
+			data: "00:00:01,000 --> 00:00:02,000\nHello there\n\n00:00:03,000 --> 00:00:04,000\nSecond cue\n",
+			want: []searchDoc{
+				{field: fieldTranscript, text: "Hello there", at: time.Second, timed: true},
+				{field: fieldTranscript, text: "Second cue", at: 3 * time.Second, timed: true},
+			},
+		},
+		{
+			name: "dot and short millis",
+			data: "1\n00:00:01.250 --> 00:00:02.000\nDot\n\n2\n00:00:03,5 --> 00:00:04,0\nHalf\n",
+			want: []searchDoc{
+				{field: fieldTranscript, text: "Dot", at: 1250 * time.Millisecond, timed: true},
+				{field: fieldTranscript, text: "Half", at: 3500 * time.Millisecond, timed: true},
+			},
+		},
+		{
+			name: "multiline text",
+			data: "1\n00:00:01,000 --> 00:00:02,000\nfirst line\nsecond line\n",
+			want: []searchDoc{
+				{field: fieldTranscript, text: "first line second line", at: time.Second, timed: true},
+			},
+		},
+		{
+			name: "empty cues",
+			data: "1\n00:00:01,000 --> 00:00:02,000\n\n2\n00:00:03,000 --> 00:00:04,000\n   \n\n3\n00:00:05,000 --> 00:00:06,000\nKept\n",
+			want: []searchDoc{
+				{field: fieldTranscript, text: "Kept", at: 5 * time.Second, timed: true},
+			},
+		},
+		{
+			name: "no timings",
+			data: "just some text\n\nmore text\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSRT(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSRT = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTranscriptCues(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "memo.txt"), []byte("first line\n\n  second line  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memo.srt"), []byte("1\n00:00:04,000 --> 00:00:05,000\nsubtitle\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sentence of three words, then a run longer than one cue
+	var words []TranscriptWord
+	for i, text := range []string{"one", "two", "three."} {
+		words = append(words, TranscriptWord{Text: text, Start: float64(i), End: float64(i) + 0.5})
+	}
+	for i := 0; i < cueWords+1; i++ {
+		start := float64(len(words))
+		words = append(words, TranscriptWord{Text: fmt.Sprintf("w%d", i), Start: start, End: start + 0.5})
+	}
+	if err := saveTranscript(dir, "memo.wav", Transcript{Engine: "fake", Words: words}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := loadTranscriptCues(dir, "memo.wav")
+	want := []searchDoc{
+		{field: fieldTranscript, text: "first line"},
+		{field: fieldTranscript, text: "second line"},
+		{field: fieldTranscript, text: "subtitle", at: 4 * time.Second, timed: true},
+		{field: fieldTranscript, text: "one two three.", at: 0, timed: true},
+		{field: fieldTranscript, text: "w0 w1 w2 w3 w4 w5 w6 w7 w8 w9 w10 w11", at: 3 * time.Second, timed: true},
+		{field: fieldTranscript, text: "w12", at: 15 * time.Second, timed: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadTranscriptCues = %+v\nwant %+v", got, want)
+	}
+
+	if cues := loadTranscriptCues(dir, "missing.wav"); len(cues) != 0 {
+		t.Errorf("cues of a memo without transcripts = %+v, want none", cues)
+	}
+}
+
+// Index memos with their .txt and .srt transcripts written to a
+// temporary directory, keyed by filename
+func testSearchIndex(t *testing.T, memos []Memo, transcripts map[string]string) *searchIndex {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range transcripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buildSearchIndex(dir, memos)
+}
+
+func TestSearchRanking(t *testing.T) {
+	created := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	memos := []Memo{
+		{Filename: "a.wav", Name: "Weekly call", Created: created},
+		{Filename: "b.wav", Name: "Budget review", Created: created},
+		{Filename: "c.wav", Name: "Meeting notes", Created: created},
+		{Filename: "d.wav", Name: "Meet Anna", Created: created},
+		{Filename: "e.wav", Name: "Groceries", Tags: []string{"budget"}, Created: created},
+	}
+	idx := testSearchIndex(t, memos, map[string]string{
+		"a.txt": "we talked about the budget\n",
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string // Filenames, best first
+	}{
+		{name: "name before tag before transcript", query: "budget", want: []string{"b.wav", "e.wav", "a.wav"}},
+		{name: "exact before prefix", query: "meet", want: []string{"d.wav", "c.wav"}},
+		{name: "prefix only", query: "meeti", want: []string{"c.wav"}},
+		{name: "case insensitive", query: "BUDGET Review", want: []string{"b.wav"}},
+		{name: "every word must match", query: "budget call", want: []string{"a.wav"}},
+		{name: "no match", query: "holiday", want: nil},
+		{name: "empty query", query: " ,. ", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, result := range idx.search(tt.query) {
+				got = append(got, result.memo.Filename)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchTiesNewestFirst(t *testing.T) {
+	created := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	idx := testSearchIndex(t, []Memo{
+		{Filename: "old.wav", Name: "Idea", Created: created},
+		{Filename: "new.wav", Name: "Idea", Created: created.Add(time.Hour)},
+	}, nil)
+
+	results := idx.search("idea")
+	if len(results) != 2 || results[0].memo.Filename != "new.wav" {
+		t.Errorf("search results = %+v, want new.wav first", results)
+	}
+}
+
+func TestSearchHit(t *testing.T) {
+	memos := []Memo{{Filename: "standup.wav", Name: "Standup deadline"}}
+	idx := testSearchIndex(t, memos, map[string]string{
+		"standup.txt": "the deadline is friday\n",
+		"standup.srt": "1\n00:00:09,000 --> 00:00:10,000\nthe deadline moved to friday\n\n" +
+			"2\n00:00:05,000 --> 00:00:06,000\nthe deadline moved\n\n" +
+			"3\n00:00:02,000 --> 00:00:03,000\nwe need a deadline\n",
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		text  string
+		at    time.Duration
+		timed bool
+	}{
+		{name: "earliest timed cue", query: "deadline", text: "we need a deadline", at: 2 * time.Second, timed: true},
+		{name: "most query words", query: "deadline friday", text: "the deadline moved to friday", at: 9 * time.Second, timed: true},
+		{name: "earliest of equal matches", query: "deadline moved", text: "the deadline moved", at: 5 * time.Second, timed: true},
+		{name: "untimed when nothing else matches", query: "standup", text: "Standup deadline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := idx.search(tt.query)
+			if len(results) != 1 {
+				t.Fatalf("search(%q) = %d results, want 1", tt.query, len(results))
+			}
+			hit := results[0].hit
+			if hit.text != tt.text || hit.at != tt.at || hit.timed != tt.timed {
+				t.Errorf("hit = %q at %v (timed %v), want %q at %v (timed %v)", hit.text, hit.at, hit.timed, tt.text, tt.at, tt.timed)
+			}
+		})
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	long := "one two three four five six seven eight nine ten eleven twelve thirteen fourteen"
+
+	tests := []struct {
+		name  string
+		text  string
+		query []string
+		width int
+		want  string
+	}{
+		{name: "fits", text: "short text", query: []string{"text"}, width: 20, want: "short text"},
+		{name: "match at start", text: long, query: []string{"one"}, width: 30, want: "one two three four five six..."},
+		{name: "match later", text: long, query: []string{"ten"}, width: 40, want: "…eight nine ten eleven twelve thirt..."},
+		{name: "prefix match", text: long, query: []string{"thirt"}, width: 40, want: "…twelve thirteen fourteen"},
+		{name: "no match", text: long, query: []string{"zero"}, width: 30, want: "one two three four five six..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchSnippet(tt.text, tt.query, tt.width); got != tt.want {
+				t.Errorf("searchSnippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// How long to wait for another voicelog instance to finish with the store
+const storeLockTimeout = 5 * time.Second
+
+// Version of the memo store layout this build reads and writes. Bump it
+// and add a step to storeMigrations whenever stored memos change shape.
+const storeSchemaVersion = 1
+
+// Store buckets and keys
+var (
+	memosBucket = []byte("memos") // Memo metadata as JSON, by filename
+	metaBucket  = []byte("meta")  // Facts about the store itself
+
+	versionKey  = []byte("schema_version") // Schema version in decimal; absent is 0
+	migratedKey = []byte("migrated")       // When a metadata.json was last imported
+)
+
+// storeMigration upgrades the store by one schema version by rewriting
+// each stored memo, as a JSON object. It runs in the transaction that
+// records the new version, so a store is never left half upgraded. Memos
+// imported from metadata.json go through the same steps.
+type storeMigration struct {
+	description string
+	migrate     func(record map[string]json.RawMessage)
+}
+
+// Upgrade steps: storeMigrations[i] takes version i to i+1. Version 0 is
+// the layout of metadata.json, which the first stores kept.
+var storeMigrations = []storeMigration{
+	{"memo names stored under \"name\" rather than \"title\"", renameTitleKey},
+}
+
+// ErrStoreLocked is returned when another voicelog instance holds the
+// store for longer than storeLockTimeout
+var ErrStoreLocked = errors.New("memo library is in use by another voicelog instance")
+
+// StoreVersionError is returned for a store written by a newer voicelog,
+// which is neither read nor changed rather than risk losing what this
+// build doesn't understand
+type StoreVersionError struct {
+	Version int
+}
+
+func (e *StoreVersionError) Error() string {
+	return fmt.Sprintf("memo library has schema version %d, but this voicelog only understands up to version %d; please upgrade voicelog",
+		e.Version, storeSchemaVersion)
+}
+
+// MemoStore keeps the metadata of memos. Changes are applied atomically
+// and only touch the memos they name, so a crash leaves the library as it
+// was before or after a change, and instances sharing a library don't
+// overwrite each other's memos.
+type MemoStore interface {
+	// All memos, in no particular order
+	Memos() ([]Memo, error)
+
+	// Add or replace the put memos and remove the memos with the given
+	// filenames, all in one transaction
+	Update(put []Memo, remove []string) error
+}
+
+// boltStore is a MemoStore in a bbolt database. The database is opened
+// for each operation, so its file lock is only held while one runs and
+// several instances can share the library.
+type boltStore struct {
+	path string
+}
+
+// Open the memo store in a memos directory, importing metadata.json the
+// first time and upgrading a store written by an earlier version. Fails
+// if the store is newer than this build or can't be upgraded. A failed
+// import is only logged: metadata.json is moved aside once its memos are
+// in the store, so the import is tried again on the next start.
+func openMemoStore(memosPath string) (MemoStore, error) {
+	s := &boltStore{path: filepath.Join(memosPath, StoreFile)}
+	if err := s.importMetadata(filepath.Join(memosPath, MetadataFile)); err != nil {
+		log.Printf("Error importing %s: %v", MetadataFile, err)
+	}
+	if err := s.upgrade(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run fn against the database, waiting for other instances to release it.
+// Stores with a newer schema are refused.
+func (s *boltStore) with(readOnly bool, fn func(*bolt.Tx) error) error {
+	return s.withAnyVersion(readOnly, func(tx *bolt.Tx) error {
+		if version := schemaVersion(tx); version > storeSchemaVersion {
+			return &StoreVersionError{Version: version}
+		}
+		return fn(tx)
+	})
+}
+
+// Run fn against the database whatever its schema version
+func (s *boltStore) withAnyVersion(readOnly bool, fn func(*bolt.Tx) error) error {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: storeLockTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return ErrStoreLocked
+	}
+	if err != nil {
+		return err
+	}
+
+	if readOnly {
+		err = db.View(fn)
+	} else {
+		err = db.Update(fn)
+	}
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *boltStore) Memos() ([]Memo, error) {
+	var memos []Memo
+	err := s.with(true, func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(memosBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var memo Memo
+			if err := json.Unmarshal(v, &memo); err != nil {
+				log.Printf("Error reading memo %s from store: %v", k, err)
+				return nil
+			}
+			memos = append(memos, memo)
+			return nil
+		})
+	})
+	return memos, err
+}
+
+func (s *boltStore) Update(put []Memo, remove []string) error {
+	return s.with(false, func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(memosBucket)
+		if err != nil {
+			return err
+		}
+		for _, filename := range remove {
+			if err := bucket.Delete([]byte(filename)); err != nil {
+				return err
+			}
+		}
+		for _, memo := range put {
+			data, err := json.Marshal(memo)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(memo.Filename), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Schema version of the store: 0 for an empty store or one written before
+// versions were recorded
+func schemaVersion(tx *bolt.Tx) int {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0
+	}
+	version, _ := strconv.Atoi(string(meta.Get(versionKey)))
+	return version
+}
+
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	return meta.Put(versionKey, []byte(strconv.Itoa(version)))
+}
+
+// Bring the store to storeSchemaVersion one migration at a time, copying
+// it to <store>.v<version>.bak before each step. An empty store is simply
+// marked with the current version.
+func (s *boltStore) upgrade() error {
+	for {
+		var version int
+		err := s.withAnyVersion(false, func(tx *bolt.Tx) error {
+			version = schemaVersion(tx)
+			if version == 0 && tx.Bucket(memosBucket) == nil {
+				version = storeSchemaVersion
+				return setSchemaVersion(tx, version)
+			}
+			return nil
+		})
+		switch {
+		case err != nil:
+			return err
+		case version > storeSchemaVersion:
+			return &StoreVersionError{Version: version}
+		case version == storeSchemaVersion:
+			return nil
+		}
+
+		backupPath := fmt.Sprintf("%s.v%d.bak", s.path, version)
+		step := storeMigrations[version]
+		err = s.withAnyVersion(false, func(tx *bolt.Tx) error {
+			// Another instance may have upgraded the store meanwhile
+			if schemaVersion(tx) != version {
+				return nil
+			}
+			if err := tx.CopyFile(backupPath, 0644); err != nil {
+				return fmt.Errorf("backing up memo store: %w", err)
+			}
+			if err := updateMemoRecords(tx, step.migrate); err != nil {
+				return err
+			}
+			return setSchemaVersion(tx, version+1)
+		})
+		if err != nil {
+			return fmt.Errorf("upgrading memo store to schema version %d (%s): %w", version+1, step.description, err)
+		}
+		log.Printf("Upgraded memo store to schema version %d, %s; backup in %s", version+1, step.description, backupPath)
+	}
+}
+
+// Rewrite every stored memo, as a JSON object, with fn
+func updateMemoRecords(tx *bolt.Tx, fn func(record map[string]json.RawMessage)) error {
+	bucket := tx.Bucket(memosBucket)
+	if bucket == nil {
+		return nil
+	}
+
+	// A bucket can't be changed while iterating over it
+	updated := make(map[string][]byte)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(v, &record); err != nil {
+			return fmt.Errorf("memo %s: %w", k, err)
+		}
+		fn(record)
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		updated[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, data := range updated {
+		if err := bucket.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Version 1 stores the name of a memo under "name", like its field,
+// instead of "title"
+func renameTitleKey(record map[string]json.RawMessage) {
+	if title, ok := record["title"]; ok {
+		if _, ok := record["name"]; !ok {
+			record["name"] = title
+		}
+		delete(record, "title")
+	}
+}
+
+// Import the memos of a metadata.json written by earlier versions, then
+// rename it out of the way. A new one appears whenever an older voicelog
+// is run on the library again, so every one is merged: memos already in
+// the store are kept and only the others are added. Its memos are in the
+// layout of schema version 0, so they are put through the migrations the
+// store has already had.
+func (s *boltStore) importMetadata(metadataPath string) error {
+	data, err := os.ReadFile(metadataPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	imported := 0
+	err = s.with(false, func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		steps := storeMigrations[:schemaVersion(tx)]
+
+		bucket, err := tx.CreateBucketIfNotExists(memosBucket)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			var filename string
+			if err := json.Unmarshal(record["filename"], &filename); err != nil || filename == "" {
+				continue
+			}
+			if bucket.Get([]byte(filename)) != nil {
+				continue
+			}
+			for _, step := range steps {
+				step.migrate(record)
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(filename), data); err != nil {
+				return err
+			}
+			imported++
+		}
+		return meta.Put(migratedKey, []byte(time.Now().Format(time.RFC3339)))
+	})
+	if err != nil {
+		return err
+	}
+	if imported > 0 {
+		log.Printf("Imported %d memo(s) from %s", imported, metadataPath)
+	}
+
+	// Kept rather than deleted, in case an older version is needed again,
+	// and numbered so an earlier import's backup is never overwritten
+	if err := os.Rename(metadataPath, uniquePath(metadataPath+".migrated")); err != nil {
+		log.Printf("Error renaming %s: %v", metadataPath, err)
+	}
+	return nil
+}
+
+// Write changed memos to the store and remove deleted ones
+func (m *Model) updateStore(put []Memo, remove ...string) {
+	if err := m.store.Update(put, remove); err != nil {
+		log.Printf("Error saving memos metadata: %v", err)
+		m.showNotification(fmt.Sprintf("Saving memos failed: %v", err))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A metadata.json of the first versions, which stored names as "title"
+const legacyMetadata = `[
+	{"id": "1", "filename": "a.wav", "title": "First", "duration": 1.5, "tags": ["work"]},
+	{"id": "2", "filename": "b.wav", "title": "Second", "duration": 2}
+]`
+
+func memosByFilename(t *testing.T, store MemoStore) map[string]Memo {
+	t.Helper()
+	memos, err := store.Memos()
+	if err != nil {
+		t.Fatalf("Memos: %v", err)
+	}
+	byFilename := make(map[string]Memo)
+	for _, memo := range memos {
+		byFilename[memo.Filename] = memo
+	}
+	return byFilename
+}
+
+func TestImportMetadata(t *testing.T) {
+	dir := t.TempDir()
+	metadataPath := filepath.Join(dir, MetadataFile)
+	if err := os.WriteFile(metadataPath, []byte(legacyMetadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openMemoStore(dir)
+	if err != nil {
+		t.Fatalf("openMemoStore: %v", err)
+	}
+	memos := memosByFilename(t, store)
+	if len(memos) != 2 || memos["a.wav"].Name != "First" || memos["b.wav"].Name != "Second" {
+		t.Errorf("imported memos = %+v", memos)
+	}
+	if _, err := os.Stat(metadataPath); !os.IsNotExist(err) {
+		t.Errorf("%s was not moved aside", MetadataFile)
+	}
+}
+
+// A metadata.json copied into a library that is already upgraded is
+// migrated on import rather than refused on every start
+func TestImportMetadataIntoUpgradedStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openMemoStore(dir)
+	if err != nil {
+		t.Fatalf("openMemoStore: %v", err)
+	}
+	if err := store.Update([]Memo{{ID: "3", Filename: "a.wav", Name: "Kept"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	metadataPath := filepath.Join(dir, MetadataFile)
+	if err := os.WriteFile(metadataPath, []byte(legacyMetadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.(*boltStore).importMetadata(metadataPath); err != nil {
+		t.Fatalf("importMetadata: %v", err)
+	}
+
+	memos := memosByFilename(t, store)
+	if memos["a.wav"].Name != "Kept" {
+		t.Errorf("memo already in the store was replaced: %+v", memos["a.wav"])
+	}
+	if memos["b.wav"].Name != "Second" {
+		t.Errorf("imported memo = %+v, want the name migrated from \"title\"", memos["b.wav"])
+	}
+	if _, err := os.Stat(metadataPath); !os.IsNotExist(err) {
+		t.Errorf("%s was not moved aside", MetadataFile)
+	}
+}
+
+// A metadata.json written by an older voicelog run after the import is
+// merged too, and the first import's backup is kept
+func TestImportMetadataAgain(t *testing.T) {
+	dir := t.TempDir()
+	metadataPath := filepath.Join(dir, MetadataFile)
+	if err := os.WriteFile(metadataPath, []byte(legacyMetadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := openMemoStore(dir)
+	if err != nil {
+		t.Fatalf("openMemoStore: %v", err)
+	}
+
+	later := `[
+	{"id": "1", "filename": "a.wav", "title": "Renamed by the old version"},
+	{"id": "3", "filename": "c.wav", "title": "Third"}
+]`
+	if err := os.WriteFile(metadataPath, []byte(later), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openMemoStore(dir); err != nil {
+		t.Fatalf("openMemoStore: %v", err)
+	}
+
+	memos := memosByFilename(t, store)
+	if len(memos) != 3 || memos["c.wav"].Name != "Third" {
+		t.Errorf("memos after the second import = %+v, want c.wav added", memos)
+	}
+	if memos["a.wav"].Name != "First" {
+		t.Errorf("memo already in the store was replaced: %+v", memos["a.wav"])
+	}
+
+	for _, backup := range []struct{ name, content string }{
+		{MetadataFile + ".migrated", legacyMetadata},
+		{MetadataFile + "_2.migrated", later},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, backup.name))
+		if err != nil || string(data) != backup.content {
+			t.Errorf("backup %s = %q, %v; want the imported file", backup.name, data, err)
+		}
+	}
+	if _, err := os.Stat(metadataPath); !os.IsNotExist(err) {
+		t.Errorf("%s was not moved aside", MetadataFile)
+	}
+}
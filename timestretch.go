@@ -0,0 +1,173 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Playback speed limits and step
+const (
+	MinPlaybackSpeed  = 0.5
+	MaxPlaybackSpeed  = 3.0
+	PlaybackSpeedStep = 0.25
+)
+
+// WSOLA parameters
+const (
+	stretchFrameTime = 30 * time.Millisecond // Analysis and synthesis window length
+	stretchTolerance = 10 * time.Millisecond // How far a window may shift to match the previous one
+	stretchDecimate  = 4                     // Frame step when comparing windows and in the coarse search
+)
+
+// timeStretcher changes playback speed without changing pitch using WSOLA
+// (waveform similarity overlap-add). Windows of the source are taken at
+// speed times the output hop and overlap-added at the output hop, each one
+// shifted within a small tolerance to line up with the waveform that
+// naturally continues the previous window. All buffers are preallocated so
+// it can run in the output callback.
+type timeStretcher struct {
+	channels  int
+	frameLen  int // Window length in frames
+	hop       int // Output hop in frames, half the window
+	tolerance int // Maximum window shift in frames
+	window    []float64
+
+	srcPos   float64   // Nominal source position of the next window, in frames
+	natural  int       // Source frame that continues the previous window, -1 if none
+	overlap  []float64 // Interleaved overlap-add accumulator, one window long
+	ready    []int16   // Interleaved output of the last completed hop
+	readyPos int       // Samples of ready already played
+	reported int64     // Sample position last published to playbackPos
+}
+
+func newTimeStretcher(sampleRate, channels int) *timeStretcher {
+	frameLen := max(64, int(float64(sampleRate)*stretchFrameTime.Seconds())/2*2)
+	hop := frameLen / 2
+
+	// A periodic Hann window sums to one at 50% overlap
+	window := make([]float64, frameLen)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameLen))
+	}
+
+	return &timeStretcher{
+		channels:  channels,
+		frameLen:  frameLen,
+		hop:       hop,
+		tolerance: int(float64(sampleRate) * stretchTolerance.Seconds()),
+		window:    window,
+		overlap:   make([]float64, frameLen*channels),
+		ready:     make([]int16, hop*channels),
+		reported:  -1,
+	}
+}
+
+// Restart stretching from a source position in frames, e.g. after a seek
+func (t *timeStretcher) reset(frame int) {
+	t.srcPos = float64(frame)
+	t.natural = -1
+	for i := range t.overlap {
+		t.overlap[i] = 0
+	}
+	t.readyPos = len(t.ready)
+}
+
+// Fill out with data played at the given speed and volume, and return the
+// new source position in samples
+func (t *timeStretcher) process(out, data []int16, speed, volume float64) int64 {
+	for i := range out {
+		if t.readyPos == len(t.ready) {
+			t.synthesize(data, speed, volume)
+		}
+		out[i] = t.ready[t.readyPos]
+		t.readyPos++
+	}
+
+	frames := len(data) / t.channels
+	if int(t.srcPos) >= frames {
+		return int64(len(data))
+	}
+	return int64(int(t.srcPos) * t.channels)
+}
+
+// Overlap-add the next window and move the completed hop to ready
+func (t *timeStretcher) synthesize(data []int16, speed, volume float64) {
+	ch := t.channels
+	frames := len(data) / ch
+
+	start := int(t.srcPos)
+	if t.natural >= 0 && start < frames {
+		start = t.bestMatch(data, start)
+	}
+
+	for n := 0; n < t.frameLen; n++ {
+		f := start + n
+		if f < 0 || f >= frames {
+			continue
+		}
+		for c := 0; c < ch; c++ {
+			t.overlap[n*ch+c] += float64(data[f*ch+c]) * t.window[n]
+		}
+	}
+
+	// The first hop of the accumulator has received all its windows
+	for i := range t.ready {
+		t.ready[i] = int16(math.Max(math.Min(t.overlap[i]*volume, 32767), -32768))
+	}
+	copy(t.overlap, t.overlap[len(t.ready):])
+	for i := len(t.overlap) - len(t.ready); i < len(t.overlap); i++ {
+		t.overlap[i] = 0
+	}
+	t.readyPos = 0
+
+	t.natural = start + t.hop
+	t.srcPos += speed * float64(t.hop)
+}
+
+// Find the window start near nominal whose waveform best matches the
+// natural continuation of the previous window, by normalized correlation
+// over the overlapping half window. Candidates are first tried every
+// stretchDecimate frames, then one by one around the best of those, which
+// keeps the search cheap enough for the output callback.
+func (t *timeStretcher) bestMatch(data []int16, nominal int) int {
+	ch := t.channels
+	frames := len(data) / ch
+	length := t.hop
+
+	// Mono sum of the source at frame f, zero outside the data
+	sample := func(f int) float64 {
+		if f < 0 || f >= frames {
+			return 0
+		}
+		var sum float64
+		for c := 0; c < ch; c++ {
+			sum += float64(data[f*ch+c])
+		}
+		return sum
+	}
+
+	best, bestScore := nominal, math.Inf(-1)
+	search := func(from, to, step int) {
+		for s := max(0, from); s <= min(to, nominal+t.tolerance); s += step {
+			var dot, energy float64
+			for n := 0; n < length; n += stretchDecimate {
+				x := sample(s + n)
+				dot += x * sample(t.natural+n)
+				energy += x * x
+			}
+			if score := dot / math.Sqrt(energy+1); score > bestScore {
+				best, bestScore = s, score
+			}
+		}
+	}
+
+	search(nominal-t.tolerance, nominal+t.tolerance, stretchDecimate)
+	coarse := best
+	search(max(coarse-stretchDecimate+1, nominal-t.tolerance), coarse+stretchDecimate-1, 1)
+	return best
+}
+
+// Clamp a playback speed to the supported range
+func clampSpeed(speed float64) float64 {
+	return math.Max(MinPlaybackSpeed, math.Min(speed, MaxPlaybackSpeed))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Play data through a time stretcher in output-callback-sized buffers until
+// the source is used up, as processAudioOutput does
+func stretch(data []int16, sampleRate, channels int, speed float64) []int16 {
+	ts := newTimeStretcher(sampleRate, channels)
+	ts.reset(0)
+
+	var out []int16
+	buf := make([]int16, 1024*channels)
+	for pos := int64(0); pos < int64(len(data)); {
+		pos = ts.process(buf, data, speed, 1)
+		out = append(out, buf...)
+	}
+	return out
+}
+
+func TestTimeStretch(t *testing.T) {
+	const sampleRate, freq = 44100, 440
+	hop := newTimeStretcher(sampleRate, 1).hop
+
+	for _, channels := range []int{1, 2} {
+		for _, speed := range []float64{MinPlaybackSpeed, 0.75, 1.5, 2, MaxPlaybackSpeed} {
+			data := sineInt16(freq, sampleRate, channels, 2)
+			out := stretch(data, sampleRate, channels, speed)
+
+			// The source position leads the output by the window being
+			// overlap-added, and the last buffer is filled whole
+			frames := len(out) / channels
+			want := float64(len(data)/channels) / speed
+			if math.Abs(float64(frames)-want) > float64(1024+2*hop) {
+				t.Errorf("%d channels at %.2fx: %d frames, want about %.0f", channels, speed, frames, want)
+			}
+
+			// The pitch is kept: the middle of the output, away from the
+			// fade in and the silence after the end, is still a 440 Hz sine
+			middle := make([]float32, 0, len(out)/2)
+			for _, v := range out[len(out)/4 : len(out)*3/4] {
+				middle = append(middle, float32(v)/32768)
+			}
+			for ch := 0; ch < channels; ch++ {
+				if got := zeroCrossingFrequency(middle, channels, ch, sampleRate); math.Abs(got-freq) > freq*0.02 {
+					t.Errorf("%d channels at %.2fx: channel %d frequency = %.1f Hz, want %d Hz", channels, speed, ch, got, freq)
+				}
+			}
+		}
+	}
+}
+
+// A stretched sine keeps its level instead of cancelling or doubling where
+// windows overlap out of phase
+func TestTimeStretchLevel(t *testing.T) {
+	const sampleRate = 44100
+	data := sineInt16(440, sampleRate, 1, 2)
+	out := stretch(data, sampleRate, 1, 1.5)
+
+	var peak, sum float64
+	middle := out[len(out)/4 : len(out)*3/4]
+	for _, v := range middle {
+		peak = math.Max(peak, math.Abs(float64(v)))
+		sum += float64(v) * float64(v)
+	}
+	rms := math.Sqrt(sum/float64(len(middle))) / 32768
+	if want := 0.5 / math.Sqrt2; math.Abs(20*math.Log10(rms/want)) > 1 {
+		t.Errorf("RMS = %.3f, want %.3f within 1 dB", rms, want)
+	}
+	if peak/32768 > 0.55 {
+		t.Errorf("peak = %.3f, want at most the source's 0.5", peak/32768)
+	}
+}
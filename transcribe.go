@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Transcription engines accepted in Config.Transcriber
+const (
+	TranscriberWhisper = "whisper"
+	TranscriberFake    = "fake"
+)
+
+// Transcript sidecar, stored next to the memo's audio file
+const transcriptSuffix = ".transcript.json"
+
+// Sample rate whisper.cpp requires of its input
+const whisperSampleRate = 16000
+
+// Transcriber turns the speech in an audio file into timed words
+type Transcriber interface {
+	Name() string
+	Transcribe(ctx context.Context, audioPath string) (Transcript, error)
+}
+
+// Transcript is the text of a memo, word by word
+type Transcript struct {
+	Engine   string           `json:"engine"`
+	Language string           `json:"language,omitempty"`
+	Created  time.Time        `json:"created"`
+	Words    []TranscriptWord `json:"words"`
+}
+
+// TranscriptWord is one word and when it is spoken, in seconds from the
+// start of the memo
+type TranscriptWord struct {
+	Text  string  `json:"text"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// The words joined into running text
+func (t Transcript) Text() string {
+	words := make([]string, len(t.Words))
+	for i, w := range t.Words {
+		words[i] = w.Text
+	}
+	return strings.Join(words, " ")
+}
+
+// transcriptMsg delivers a transcript made in the background
+type transcriptMsg struct {
+	filename   string
+	transcript Transcript
+	err        error
+}
+
+// Create the transcriber selected in the config, or nil for none.
+// VOICELOG_TRANSCRIBER overrides the config, e.g. on CI machines.
+func newTranscriber(config Config) Transcriber {
+	name := config.Transcriber
+	if env := os.Getenv("VOICELOG_TRANSCRIBER"); env != "" {
+		name = env
+	}
+
+	switch name {
+	case "":
+		return nil
+	case TranscriberWhisper:
+		return whisperTranscriber{binary: config.WhisperPath, model: config.WhisperModel, language: config.WhisperLanguage}
+	case TranscriberFake:
+		return fakeTranscriber{}
+	default:
+		log.Printf("Unknown transcriber %q, transcription disabled", name)
+		return nil
+	}
+}
+
+// whisperTranscriber runs the whisper.cpp command line program, which
+// works offline with a local model file
+type whisperTranscriber struct {
+	binary   string // Path to whisper-cli (called main in older releases)
+	model    string // Path to a ggml model file
+	language string // Spoken language, or "auto" to detect it
+}
+
+func (w whisperTranscriber) Name() string { return TranscriberWhisper }
+
+// Convert the audio to the 16 kHz mono WAV whisper.cpp reads, run it with
+// one word per segment and read back its JSON output
+func (w whisperTranscriber) Transcribe(ctx context.Context, audioPath string) (Transcript, error) {
+	if w.binary == "" || w.model == "" {
+		return Transcript{}, fmt.Errorf("whisper: whisper_path and whisper_model must be set in the config")
+	}
+
+	samples, sampleRate, channels, err := readAudioData(audioPath)
+	if err != nil {
+		return Transcript{}, err
+	}
+	mono := resampleInt16(convertChannels(samples, channels, 1), 1, sampleRate, whisperSampleRate)
+	input := make([]float32, len(mono))
+	for i, s := range mono {
+		input[i] = float32(s) / 32768
+	}
+
+	dir, err := os.MkdirTemp("", "voicelog-whisper-")
+	if err != nil {
+		return Transcript{}, err
+	}
+	defer os.RemoveAll(dir)
+	inputPath := filepath.Join(dir, "input.wav")
+	if err := writeWAVFile(inputPath, input, whisperSampleRate, 1, 16); err != nil {
+		return Transcript{}, err
+	}
+
+	language := w.language
+	if language == "" {
+		language = "auto"
+	}
+	outputBase := filepath.Join(dir, "output")
+	cmd := exec.CommandContext(ctx, w.binary,
+		"-m", w.model,
+		"-f", inputPath,
+		"-l", language,
+		"-ml", "1", "-sow", // One word per segment
+		"-oj", "-of", outputBase,
+		"-np")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		// The last line of output usually says what went wrong
+		if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); lines[len(lines)-1] != "" {
+			err = fmt.Errorf("%w: %s", err, lines[len(lines)-1])
+		}
+		return Transcript{}, fmt.Errorf("whisper: %w", err)
+	}
+
+	data, err := os.ReadFile(outputBase + ".json")
+	if err != nil {
+		return Transcript{}, fmt.Errorf("whisper: %w", err)
+	}
+	return parseWhisperJSON(data)
+}
+
+// Read the words from whisper.cpp JSON output. Offsets are in
+// milliseconds; markers such as [BLANK_AUDIO] are left out.
+func parseWhisperJSON(data []byte) (Transcript, error) {
+	var output struct {
+		Result struct {
+			Language string `json:"language"`
+		} `json:"result"`
+		Transcription []struct {
+			Offsets struct {
+				From int64 `json:"from"`
+				To   int64 `json:"to"`
+			} `json:"offsets"`
+			Text string `json:"text"`
+		} `json:"transcription"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return Transcript{}, fmt.Errorf("whisper: reading output: %w", err)
+	}
+
+	transcript := Transcript{Engine: TranscriberWhisper, Language: output.Result.Language, Created: time.Now()}
+	for _, segment := range output.Transcription {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" || strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			continue
+		}
+		transcript.Words = append(transcript.Words, TranscriptWord{
+			Text:  text,
+			Start: float64(segment.Offsets.From) / 1000,
+			End:   float64(segment.Offsets.To) / 1000,
+		})
+	}
+	return transcript, nil
+}
+
+// fakeTranscriber spreads a fixed sentence evenly over the audio, so the
+// transcription flow runs without a speech engine
+type fakeTranscriber struct{}
+
+func (fakeTranscriber) Name() string { return TranscriberFake }
+
+func (fakeTranscriber) Transcribe(ctx context.Context, audioPath string) (Transcript, error) {
+	samples, sampleRate, channels, err := readAudioData(audioPath)
+	if err != nil {
+		return Transcript{}, err
+	}
+	duration := float64(len(samples)/max(channels, 1)) / float64(max(sampleRate, 1))
+
+	words := strings.Fields("the quick brown fox jumps over the lazy dog")
+	transcript := Transcript{Engine: TranscriberFake, Language: "en", Created: time.Now()}
+	step := duration / float64(len(words))
+	for i, word := range words {
+		transcript.Words = append(transcript.Words, TranscriptWord{
+			Text:  word,
+			Start: float64(i) * step,
+			End:   float64(i+1) * step,
+		})
+	}
+	return transcript, ctx.Err()
+}
+
+// Path of a memo's transcript sidecar
+func transcriptPath(memosPath, filename string) string {
+	return filepath.Join(memosPath, strings.TrimSuffix(filename, filepath.Ext(filename))+transcriptSuffix)
+}
+
+// Load a memo's transcript, if it has one
+func loadTranscript(memosPath, filename string) (Transcript, error) {
+	var transcript Transcript
+	data, err := os.ReadFile(transcriptPath(memosPath, filename))
+	if err != nil {
+		return transcript, err
+	}
+	err = json.Unmarshal(data, &transcript)
+	return transcript, err
+}
+
+// Save a memo's transcript next to its audio, replacing any earlier one
+// only once the new one is written in full
+func saveTranscript(memosPath, filename string, transcript Transcript) error {
+	data, err := json.MarshalIndent(transcript, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(transcriptPath(memosPath, filename), func(file *os.File) error {
+		_, err := file.Write(data)
+		return err
+	})
+}
+
+// Transcribe a memo off the UI goroutine
+func runTranscriber(ctx context.Context, transcriber Transcriber, memosPath, filename string) tea.Cmd {
+	return func() tea.Msg {
+		transcript, err := transcriber.Transcribe(ctx, filepath.Join(memosPath, filename))
+		return transcriptMsg{filename: filename, transcript: transcript, err: err}
+	}
+}
+
+// Queue a memo for transcription, if a transcriber is configured
+func (m *Model) queueTranscription(filename string) bool {
+	if m.transcriber == nil {
+		return false
+	}
+	if !m.isQueued(filename) && m.transcribing != filename {
+		m.transcribeQueue = append(m.transcribeQueue, filename)
+	}
+	delete(m.transcriptErrors, filename)
+	return true
+}
+
+// Transcribe the selected memo, again if it already has a transcript
+func (m *Model) transcribeSelected() {
+	if m.selectedIdx < 0 || m.selectedIdx >= len(m.memos) {
+		return
+	}
+	if !m.queueTranscription(m.memos[m.selectedIdx].Filename) {
+		m.showNotification("No transcriber configured")
+		return
+	}
+	m.showNotification("Transcription queued")
+}
+
+// Start transcribing the next queued memo when none is being transcribed.
+// One runs at a time, as engines use every core they get.
+func (m *Model) requestTranscription() tea.Cmd {
+	if m.transcribing != "" || len(m.transcribeQueue) == 0 || m.transcriber == nil {
+		return nil
+	}
+	m.transcribing = m.transcribeQueue[0]
+	m.transcribeQueue = m.transcribeQueue[1:]
+	log.Printf("Transcribing %s with %s", m.transcribing, m.transcriber.Name())
+	return runTranscriber(m.transcriberCtx, m.transcriber, m.config.MemosPath, m.transcribing)
+}
+
+// Store a finished transcript next to its memo
+func (m *Model) handleTranscript(msg transcriptMsg) {
+	m.transcribing = ""
+	if msg.err != nil {
+		log.Printf("Error transcribing %s: %v", msg.filename, msg.err)
+		m.transcriptErrors[msg.filename] = msg.err.Error()
+		return
+	}
+
+	if m.memoSize(msg.filename) < 0 {
+		// Deleted while it was being transcribed
+		return
+	}
+	if err := saveTranscript(m.config.MemosPath, msg.filename, msg.transcript); err != nil {
+		log.Printf("Error saving transcript: %v", err)
+	}
+	m.transcripts[msg.filename] = &msg.transcript
+	log.Printf("Transcribed %s: %d words", msg.filename, len(msg.transcript.Words))
+}
+
+// Load the selected memo's transcript from disk the first time it is
+// shown. A nil transcript is cached for memos without one.
+func (m *Model) loadSelectedTranscript() {
+	if m.selectedIdx < 0 || m.selectedIdx >= len(m.memos) {
+		return
+	}
+	filename := m.memos[m.selectedIdx].Filename
+	if _, ok := m.transcripts[filename]; ok {
+		return
+	}
+	var transcript *Transcript
+	if t, err := loadTranscript(m.config.MemosPath, filename); err == nil {
+		transcript = &t
+	} else if !os.IsNotExist(err) {
+		log.Printf("Error loading transcript of %s: %v", filename, err)
+	}
+	m.transcripts[filename] = transcript
+}
+
+// Render the transcript pane for the selected memo in at most height
+// lines, highlighting the word being played and keeping it in view
+func (m Model) renderTranscript(width, height int) string {
+	if m.selectedIdx < 0 || m.selectedIdx >= len(m.memos) || height < 1 {
+		return ""
+	}
+	memo := m.memos[m.selectedIdx]
+	transcript := m.transcripts[memo.Filename]
+	width = max(10, width)
+
+	lines := []string{normalStyle.Render("Transcript"), ""}
+	var status string
+	switch {
+	case m.transcribing == memo.Filename:
+		status = "Transcribing..."
+	case m.transcriptErrors[memo.Filename] != "":
+		status = "Transcription failed: " + m.transcriptErrors[memo.Filename]
+	case transcript == nil && m.isQueued(memo.Filename):
+		status = "Waiting to transcribe..."
+	case transcript == nil:
+		status = fmt.Sprintf("No transcript. Press %s to transcribe.", keys.Transcribe.Help().Key)
+	case len(transcript.Words) == 0:
+		status = "No speech found"
+	}
+	if status != "" {
+		lines = append(lines, mutedStyle.Render(truncateText(status, width)))
+		return transcriptPaneStyle(width, height).Render(strings.Join(lines, "\n"))
+	}
+
+	// The word at the playhead, when this memo is playing
+	current := -1
+	if (m.playing || m.playbackPaused()) && m.audioDevice != nil && m.audioDevice.playbackMemo == memo.Filename {
+		at := m.playbackPos.Seconds()
+		for i, w := range transcript.Words {
+			if w.Start <= at {
+				current = i
+			}
+		}
+	}
+
+	// Wrap the words, remembering the line with the current word
+	var text []string
+	var line strings.Builder
+	lineWidth, currentLine := 0, 0
+	for i, w := range transcript.Words {
+		word := truncateText(w.Text, width)
+		if lineWidth > 0 && lineWidth+1+lipgloss.Width(word) > width {
+			text = append(text, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+		if lineWidth > 0 {
+			line.WriteString(" ")
+			lineWidth++
+		}
+		if i == current {
+			currentLine = len(text)
+			line.WriteString(overviewPlayheadStyle.Render(word))
+		} else {
+			line.WriteString(word)
+		}
+		lineWidth += lipgloss.Width(word)
+	}
+	text = append(text, line.String())
+
+	rows := max(1, height-len(lines))
+	first := max(0, min(currentLine-rows/2, len(text)-rows))
+	lines = append(lines, text[first:min(len(text), first+rows)]...)
+	return transcriptPaneStyle(width, height).Render(strings.Join(lines, "\n"))
+}
+
+// The bordered pane style sized to hold width by height characters
+func transcriptPaneStyle(width, height int) lipgloss.Style {
+	return memoListBorderStyle.Width(width + 4).Height(height + 2)
+}
+
+// Whether a memo is waiting for transcription
+func (m Model) isQueued(filename string) bool {
+	for _, queued := range m.transcribeQueue {
+		if queued == filename {
+			return true
+		}
+	}
+	return false
+}
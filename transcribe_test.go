@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Point HOME at a new directory whose config records from input and plays
+// to output through the file backend, and return that config
+func fileBackendHome(t *testing.T, input, output string) Config {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VOICELOG_AUDIO_BACKEND", "")
+	t.Setenv("VOICELOG_TRANSCRIBER", "")
+
+	config := defaultConfig()
+	config.AudioBackend = BackendFile
+	config.BackendInputFile = input
+	config.BackendOutputFile = output
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// A three second 440 Hz fixture to record from
+func writeToneWAV(t *testing.T) string {
+	t.Helper()
+	samples := sineInt16(440, 44100, 1, 3)
+	input := make([]float32, len(samples))
+	for i, s := range samples {
+		input[i] = float32(s) / 32768
+	}
+	path := filepath.Join(t.TempDir(), "tone.wav")
+	if err := writeWAVFile(path, input, 44100, 1, 16); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Record through the file backend with the fake engine and check the
+// transcript saved next to the memo
+func TestRecordTranscript(t *testing.T) {
+	config := fileBackendHome(t, writeToneWAV(t), "")
+	t.Setenv("VOICELOG_TRANSCRIBER", TranscriberFake)
+
+	var stdout, stderr bytes.Buffer
+	if status := runCommand([]string{"record", "--duration", "1s", "--json"}, &stdout, &stderr); status != 0 {
+		t.Fatalf("record exited with %d: %s", status, stderr.String())
+	}
+	var memo memoOutput
+	if err := json.Unmarshal(stdout.Bytes(), &memo); err != nil {
+		t.Fatalf("record output: %v\n%s", err, stdout.String())
+	}
+
+	sidecar := filepath.Join(config.MemosPath, strings.TrimSuffix(memo.Filename, filepath.Ext(memo.Filename))+transcriptSuffix)
+	if _, err := os.Stat(sidecar); err != nil {
+		t.Fatalf("no transcript sidecar: %v", err)
+	}
+	transcript, err := loadTranscript(config.MemosPath, memo.Filename)
+	if err != nil {
+		t.Fatalf("loadTranscript: %v", err)
+	}
+	if transcript.Engine != TranscriberFake || memo.Transcript != transcript.Text() {
+		t.Errorf("transcript = %+v, printed %q", transcript, memo.Transcript)
+	}
+
+	words := strings.Fields("the quick brown fox jumps over the lazy dog")
+	if len(transcript.Words) != len(words) {
+		t.Fatalf("got %d words, want %d", len(transcript.Words), len(words))
+	}
+	step := memo.Duration / float64(len(words))
+	for i, word := range transcript.Words {
+		if word.Text != words[i] {
+			t.Errorf("word %d = %q, want %q", i, word.Text, words[i])
+		}
+		if math.Abs(word.Start-float64(i)*step) > 0.01 || math.Abs(word.End-float64(i+1)*step) > 0.01 {
+			t.Errorf("word %d spans %.3f-%.3fs, want %.3f-%.3fs", i, word.Start, word.End, float64(i)*step, float64(i+1)*step)
+		}
+	}
+	if last := transcript.Words[len(words)-1].End; math.Abs(last-memo.Duration) > 0.01 || memo.Duration < 0.9 {
+		t.Errorf("last word ends at %.3fs in a %.3fs memo", last, memo.Duration)
+	}
+}
+
+// Saving replaces an earlier transcript and leaves no temporary file behind
+func TestSaveTranscript(t *testing.T) {
+	dir := t.TempDir()
+	for _, text := range []string{"first", "second"} {
+		transcript := Transcript{Engine: "fake", Words: []TranscriptWord{{Text: text, Start: 0, End: 0.5}}}
+		if err := saveTranscript(dir, "memo.wav", transcript); err != nil {
+			t.Fatalf("saveTranscript: %v", err)
+		}
+	}
+
+	transcript, err := loadTranscript(dir, "memo.wav")
+	if err != nil {
+		t.Fatalf("loadTranscript: %v", err)
+	}
+	if got := transcript.Text(); got != "second" {
+		t.Errorf("transcript = %q, want %q", got, "second")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(transcriptPath(dir, "memo.wav")) {
+		t.Errorf("directory holds %v, want only the transcript", entries)
+	}
+}
+
+// testdata/whisper_jfk.json is laid out as whisper-cli writes it with
+// -ml 1 -sow -oj, the flags whisperTranscriber passes
+func TestParseWhisperJSON(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "whisper_jfk.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript, err := parseWhisperJSON(data)
+	if err != nil {
+		t.Fatalf("parseWhisperJSON: %v", err)
+	}
+
+	if transcript.Engine != TranscriberWhisper || transcript.Language != "en" {
+		t.Errorf("engine %q, language %q", transcript.Engine, transcript.Language)
+	}
+	want := "And so my fellow Americans, ask not what your country can do for you, ask what you can do for your country."
+	if got := transcript.Text(); got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+
+	// The empty first segment and [BLANK_AUDIO] are dropped
+	if len(transcript.Words) != 22 {
+		t.Fatalf("got %d words, want 22", len(transcript.Words))
+	}
+	first, last := transcript.Words[0], transcript.Words[len(transcript.Words)-1]
+	if first.Text != "And" || first.Start != 0.32 || first.End != 0.55 {
+		t.Errorf("first word = %+v, want And at 0.32-0.55s", first)
+	}
+	if last.Text != "country." || last.Start != 9.17 || last.End != 11 {
+		t.Errorf("last word = %+v, want country. at 9.17-11s", last)
+	}
+	for i := 1; i < len(transcript.Words); i++ {
+		if transcript.Words[i].Start < transcript.Words[i-1].End {
+			t.Errorf("word %d starts before the previous one ends", i)
+		}
+	}
+}
+
+func TestParseWhisperJSONErrors(t *testing.T) {
+	if _, err := parseWhisperJSON([]byte("whisper_init_from_file: failed to open")); err == nil {
+		t.Error("parseWhisperJSON accepted output that is not JSON")
+	}
+	transcript, err := parseWhisperJSON([]byte(`{"result": {"language": "de"}, "transcription": []}`))
+	if err != nil || len(transcript.Words) != 0 || transcript.Language != "de" {
+		t.Errorf("silent audio = %+v, %v", transcript, err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Voice activation defaults and settings ranges
+const (
+	DefaultVoiceThreshold = -40.0 // dBFS
+	DefaultVoiceHangover  = 1.5   // seconds
+	MinVoiceThreshold     = -60.0
+	MaxVoiceThreshold     = -10.0
+	VoiceThresholdStep    = 5.0
+	MinVoiceHangover      = 0.5
+	MaxVoiceHangover      = 5.0
+	VoiceHangoverStep     = 0.5
+)
+
+// Silence timeouts offered in the settings, in seconds; 0 never stops
+var silenceTimeouts = []float64{0, 10, 30, 60, 120, 300}
+
+// voiceGate decides from the input level which audio a voice-activated
+// recording keeps. The gate opens on any block whose peak reaches the
+// threshold and stays open for the hangover after the level drops, so
+// pauses between words are kept. It starts closed.
+type voiceGate struct {
+	threshold  float32 // Linear peak level that opens the gate
+	hangover   int64   // Frames the gate stays open after the last loud block
+	channels   int
+	sampleRate int
+
+	quiet atomic.Int64 // Frames since the last loud block, written by the input callback
+}
+
+func newVoiceGate(config Config, sampleRate, channels int) *voiceGate {
+	g := &voiceGate{
+		threshold:  float32(math.Pow(10, config.VoiceThreshold/20)),
+		hangover:   int64(config.VoiceHangover * float64(sampleRate)),
+		channels:   channels,
+		sampleRate: sampleRate,
+	}
+	g.quiet.Store(g.hangover + 1)
+	return g
+}
+
+// Feed a block of interleaved input and report whether it should be
+// recorded. Called from the input callback, so it must not block.
+func (g *voiceGate) process(in []float32) bool {
+	var peak float32
+	for _, v := range in {
+		if v > peak {
+			peak = v
+		} else if -v > peak {
+			peak = -v
+		}
+	}
+
+	quiet := g.quiet.Load() + int64(len(in)/g.channels)
+	if peak >= g.threshold {
+		quiet = 0
+	}
+	g.quiet.Store(quiet)
+	return quiet <= g.hangover
+}
+
+// Whether input is currently being recorded
+func (g *voiceGate) open() bool {
+	return g.quiet.Load() <= g.hangover
+}
+
+// Time since the input was last above the threshold
+func (g *voiceGate) silence() time.Duration {
+	return time.Duration(float64(g.quiet.Load()) / float64(g.sampleRate) * float64(time.Second))
+}
+
+// Stop a voice-activated recording that has been silent for the configured
+// timeout. Returns the command saving it, or nil if it goes on.
+func (m *Model) checkSilenceTimeout() tea.Cmd {
+	if !m.recording || m.recordingPaused || m.audioDevice == nil || m.audioDevice.voiceGate == nil {
+		return nil
+	}
+	timeout := time.Duration(m.config.SilenceTimeout * float64(time.Second))
+	if timeout <= 0 || m.audioDevice.voiceGate.silence() < timeout {
+		return nil
+	}
+
+	cmd := m.stopRecording()
+	m.showNotification(fmt.Sprintf("Recording stopped after %s of silence", secondsLabel(m.config.SilenceTimeout)))
+	return cmd
+}
+
+// Whether a voice-activated recording is waiting for voice
+func (m Model) voiceGateClosed() bool {
+	return m.recording && m.audioDevice != nil && m.audioDevice.voiceGate != nil && !m.audioDevice.voiceGate.open()
+}
+
+// Settings label for a time in seconds, where 0 is off
+func secondsLabel(seconds float64) string {
+	if seconds <= 0 {
+		return "Off"
+	}
+	if seconds >= 60 {
+		return fmt.Sprintf("%g min", seconds/60)
+	}
+	return fmt.Sprintf("%g s", seconds)
+}
+
+// Settings label for a switch
+func onOffLabel(on bool) string {
+	if on {
+		return "On"
+	}
+	return "Off"
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// VU meter scale and ballistics
+const (
+	vuFloorDB      = -60.0                  // Bottom of the meter scale in dBFS
+	vuWarnDB       = -18.0                  // Start of the amber zone
+	vuHotDB        = -6.0                   // Start of the red zone
+	vuRMSTime      = 300 * time.Millisecond // Time for the RMS reading to settle within 1% of a step
+	vuPeakFall     = 20.0                   // Peak fall-off in dB per second
+	vuPeakHoldTime = 1500 * time.Millisecond
+	vuClipLevel    = 0.999 // Samples at or above this count as clipping
+	vuClipHoldTime = 2 * time.Second
+)
+
+// meterChannel is the meter state of one channel. Levels are linear, with
+// 1.0 at full scale, so the zero value reads as silence.
+type meterChannel struct {
+	rms      float64 // Averaged with VU ballistics
+	peak     float64 // Instant attack, constant fall in dB
+	peakHold float64 // Highest recent peak
+	clipped  bool    // A sample reached full scale recently
+
+	holdAge time.Duration // Time since peakHold was set
+	clipAge time.Duration // Time since the last clipped sample
+}
+
+// Feed a block of interleaved samples into the left and right meters. Mono
+// input drives both.
+func (v *VUMeterData) update(samples []float32, channels int, dt time.Duration) {
+	for i, meter := range []*meterChannel{&v.left, &v.right} {
+		var sum, peak float64
+		n := 0
+		for j := min(i, channels-1); j < len(samples); j += channels {
+			x := math.Abs(float64(samples[j]))
+			sum += x * x
+			peak = math.Max(peak, x)
+			n++
+		}
+
+		var rms float64
+		if n > 0 {
+			rms = math.Sqrt(sum / float64(n))
+		}
+		meter.update(rms, peak, peak >= vuClipLevel, dt)
+	}
+}
+
+// Apply one block's readings with meter ballistics
+func (c *meterChannel) update(rms, peak float64, clipped bool, dt time.Duration) {
+	// RMS integrates power exponentially, settling within 1% in vuRMSTime
+	alpha := 1 - math.Exp(-dt.Seconds()*math.Log(100)/vuRMSTime.Seconds())
+	power := c.rms * c.rms
+	c.rms = math.Sqrt(power + (rms*rms-power)*alpha)
+
+	// Peaks rise instantly and fall at a constant rate in dB
+	fall := math.Pow(10, -vuPeakFall*dt.Seconds()/20)
+	c.peak = math.Max(peak, c.peak*fall)
+
+	// The highest peak is held for a while before it falls too
+	c.holdAge += dt
+	if peak >= c.peakHold {
+		c.peakHold = peak
+		c.holdAge = 0
+	} else if c.holdAge > vuPeakHoldTime {
+		c.peakHold = math.Max(c.peak, c.peakHold*fall)
+	}
+
+	c.clipAge += dt
+	if clipped {
+		c.clipped = true
+		c.clipAge = 0
+	} else if c.clipAge > vuClipHoldTime {
+		c.clipped = false
+	}
+}
+
+// Convert a linear level to dBFS, clamped to the bottom of the meter scale
+func dBFS(level float64) float64 {
+	if level <= 0 {
+		return vuFloorDB
+	}
+	return math.Max(20*math.Log10(level), vuFloorDB)
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// WAV format tags
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatIEEEFloat  = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// Trailing 14 bytes of the KSDATAFORMAT_SUBTYPE GUIDs used by
+// WAVE_FORMAT_EXTENSIBLE; the first two bytes hold the format tag
+var wavSubformatSuffix = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71,
+}
+
+// Data chunks with this size were never finalized, e.g. by streaming writers
+const wavUnknownSize = 0xFFFFFFFF
+
+// Size of the canonical header written by writeWAVHeader
+const wavHeaderSize = 44
+
+// Bytes of a fmt chunk that are read; WAVE_FORMAT_EXTENSIBLE needs 40 and
+// anything past that is skipped, so a corrupt size can't force a large
+// allocation
+const wavMaxFormatSize = 64
+
+// UnsupportedWAVError reports a WAV file whose sample encoding cannot be
+// decoded, such as ADPCM, µ-law or 12-bit PCM
+type UnsupportedWAVError struct {
+	FormatTag     uint16
+	BitsPerSample int
+}
+
+func (e *UnsupportedWAVError) Error() string {
+	switch e.FormatTag {
+	case wavFormatPCM:
+		return fmt.Sprintf("unsupported WAV encoding: %d-bit PCM", e.BitsPerSample)
+	case wavFormatIEEEFloat:
+		return fmt.Sprintf("unsupported WAV encoding: %d-bit float", e.BitsPerSample)
+	default:
+		return fmt.Sprintf("unsupported WAV encoding: format 0x%04X, %d bits per sample", e.FormatTag, e.BitsPerSample)
+	}
+}
+
+// wavInfo describes the sample format and the location of the samples in
+// a WAV file
+type wavInfo struct {
+	FormatTag     uint16 // PCM or IEEE float, resolved from extensible headers
+	Channels      int
+	SampleRate    int
+	BitsPerSample int // Container size of one sample
+	BlockAlign    int // Bytes per frame
+
+	DataOffset int64
+	DataSize   int64
+}
+
+// Walk the RIFF chunks of a WAV file, in whatever order they appear, and
+// return the format and data chunk location. Unknown chunks such as LIST,
+// fact or cue are skipped.
+func parseWAV(r io.ReadSeeker) (wavInfo, error) {
+	var info wavInfo
+
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return info, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return info, err
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return info, fmt.Errorf("not a valid WAV file")
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return info, fmt.Errorf("not a valid WAV file")
+	}
+
+	haveFormat, haveData := false, false
+	pos := int64(12)
+	chunk := make([]byte, 8)
+	for !(haveFormat && haveData) {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			break
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		pos += 8
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return info, fmt.Errorf("WAV format chunk too short: %d bytes", size)
+			}
+			if pos+size > fileSize {
+				return info, fmt.Errorf("WAV format chunk truncated: %d of %d bytes", fileSize-pos, size)
+			}
+			data := make([]byte, min(int(size), wavMaxFormatSize))
+			if _, err := io.ReadFull(r, data); err != nil {
+				return info, fmt.Errorf("reading WAV format chunk: %w", err)
+			}
+			if err := info.parseFormat(data); err != nil {
+				return info, err
+			}
+			haveFormat = true
+
+		case "data":
+			info.DataOffset = pos
+
+			// An unfinalized or truncated data chunk runs to the end of the file
+			if size == wavUnknownSize || pos+size > fileSize {
+				size = fileSize - pos
+			}
+			info.DataSize = size
+			haveData = true
+		}
+
+		// Chunks are padded to an even size
+		pos += size + size&1
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return info, err
+		}
+	}
+
+	if !haveFormat {
+		return info, fmt.Errorf("WAV file has no format chunk")
+	}
+	if !haveData {
+		return info, fmt.Errorf("WAV file has no data chunk")
+	}
+	return info, nil
+}
+
+// Parse a fmt chunk, resolving WAVE_FORMAT_EXTENSIBLE to its subformat
+func (info *wavInfo) parseFormat(data []byte) error {
+	info.FormatTag = binary.LittleEndian.Uint16(data[0:2])
+	info.Channels = int(binary.LittleEndian.Uint16(data[2:4]))
+	info.SampleRate = int(binary.LittleEndian.Uint32(data[4:8]))
+	info.BlockAlign = int(binary.LittleEndian.Uint16(data[12:14]))
+	info.BitsPerSample = int(binary.LittleEndian.Uint16(data[14:16]))
+
+	if info.FormatTag == wavFormatExtensible {
+		if len(data) < 40 {
+			return fmt.Errorf("WAV extensible format chunk too short: %d bytes", len(data))
+		}
+		subformat := data[24:40]
+		if !bytes.Equal(subformat[2:], wavSubformatSuffix) {
+			return &UnsupportedWAVError{FormatTag: wavFormatExtensible, BitsPerSample: info.BitsPerSample}
+		}
+		info.FormatTag = binary.LittleEndian.Uint16(subformat[0:2])
+	}
+
+	if info.Channels <= 0 {
+		return fmt.Errorf("invalid WAV channel count: %d", info.Channels)
+	}
+	if info.SampleRate <= 0 {
+		return fmt.Errorf("invalid WAV sample rate: %d", info.SampleRate)
+	}
+
+	switch {
+	case info.FormatTag == wavFormatPCM && info.BitsPerSample%8 == 0 && info.BitsPerSample >= 8 && info.BitsPerSample <= 32:
+	case info.FormatTag == wavFormatIEEEFloat && info.BitsPerSample == 32:
+	default:
+		return &UnsupportedWAVError{FormatTag: info.FormatTag, BitsPerSample: info.BitsPerSample}
+	}
+
+	// Some writers leave the block alignment at zero
+	info.BlockAlign = max(info.BlockAlign, info.Channels*info.BitsPerSample/8)
+	return nil
+}
+
+// Decode one sample at the start of b to 16 bits
+func (info *wavInfo) sample16(b []byte) int16 {
+	switch {
+	case info.FormatTag == wavFormatIEEEFloat:
+		return floatToInt16(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+	case info.BitsPerSample == 8:
+		// 8-bit PCM is unsigned
+		return int16(int(b[0])-128) << 8
+	case info.BitsPerSample == 16:
+		return int16(binary.LittleEndian.Uint16(b))
+	case info.BitsPerSample == 24:
+		return int16(uint16(b[1]) | uint16(b[2])<<8)
+	default:
+		return int16(binary.LittleEndian.Uint16(b[2:4]))
+	}
+}
+
+// Decode one sample at the start of b to a float in [-1, 1]
+func (info *wavInfo) sampleFloat(b []byte) float32 {
+	switch {
+	case info.FormatTag == wavFormatIEEEFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	case info.BitsPerSample == 8:
+		return float32(int(b[0])-128) / 128
+	case info.BitsPerSample == 16:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+	case info.BitsPerSample == 24:
+		return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / 8388608
+	default:
+		return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+	}
+}
+
+// Read a WAV file as interleaved 16-bit samples, converting from any of
+// the supported encodings
+func readWAVData(filePath string) ([]int16, int, int, error) {
+	data, info, err := readWAVChunk(filePath)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	bytesPerSample := info.BitsPerSample / 8
+	frames := len(data) / info.BlockAlign
+	samples := make([]int16, frames*info.Channels)
+	for i := 0; i < frames; i++ {
+		frame := data[i*info.BlockAlign:]
+		for ch := 0; ch < info.Channels; ch++ {
+			samples[i*info.Channels+ch] = info.sample16(frame[ch*bytesPerSample:])
+		}
+	}
+
+	return samples, info.SampleRate, info.Channels, nil
+}
+
+// Read a WAV file as interleaved samples in [-1, 1] at full precision
+func readWAVFloat(filePath string) ([]float32, wavInfo, error) {
+	data, info, err := readWAVChunk(filePath)
+	if err != nil {
+		return nil, info, err
+	}
+
+	bytesPerSample := info.BitsPerSample / 8
+	frames := len(data) / info.BlockAlign
+	samples := make([]float32, frames*info.Channels)
+	for i := 0; i < frames; i++ {
+		frame := data[i*info.BlockAlign:]
+		for ch := 0; ch < info.Channels; ch++ {
+			samples[i*info.Channels+ch] = info.sampleFloat(frame[ch*bytesPerSample:])
+		}
+	}
+
+	return samples, info, nil
+}
+
+// Read the format and data chunk location of a WAV file without its samples
+func readWAVInfo(filePath string) (wavInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return wavInfo{}, err
+	}
+	defer file.Close()
+	return parseWAV(file)
+}
+
+// Read the format and the raw data chunk of a WAV file
+func readWAVChunk(filePath string) ([]byte, wavInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, wavInfo{}, err
+	}
+	defer file.Close()
+
+	info, err := parseWAV(file)
+	if err != nil {
+		return nil, info, err
+	}
+
+	if _, err := file.Seek(info.DataOffset, io.SeekStart); err != nil {
+		return nil, info, err
+	}
+	data := make([]byte, info.DataSize)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, info, err
+	}
+	return data, info, nil
+}
+
+// Write WAV file header. 32-bit samples are stored as IEEE float, other
+// sizes as integer PCM.
+func writeWAVHeader(file *os.File, sampleRate, channels, bitsPerSample int, dataSize int64) error {
+	formatTag := uint16(wavFormatPCM)
+	if bitsPerSample == 32 {
+		formatTag = wavFormatIEEEFloat
+	}
+
+	// WAV header structure
+	header := make([]byte, wavHeaderSize)
+
+	// RIFF header
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+dataSize))
+	copy(header[8:12], "WAVE")
+
+	// fmt chunk
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16) // fmt chunk size
+	binary.LittleEndian.PutUint16(header[20:22], formatTag)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(sampleRate*channels*bitsPerSample/8))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(channels*bitsPerSample/8))
+	binary.LittleEndian.PutUint16(header[34:36], uint16(bitsPerSample))
+
+	// data chunk
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
+
+	_, err := file.Write(header)
+	return err
+}
+
+// Patch the RIFF and data chunk sizes of a header written by writeWAVHeader
+// to match the current file size
+func finalizeWAVHeader(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	dataSize := info.Size() - wavHeaderSize
+	if dataSize < 0 {
+		return fmt.Errorf("WAV file too short: %d bytes", info.Size())
+	}
+
+	sizes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sizes, uint32(36+dataSize))
+	if _, err := file.WriteAt(sizes, 4); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(sizes, uint32(dataSize))
+	_, err = file.WriteAt(sizes, 40)
+	return err
+}
+
+// Repair the sizes in the header of a WAV file whose writer never finalized
+// it, e.g. after a crash mid-recording. Only a data chunk at the end of the
+// file is extended; reports whether the header was changed.
+func repairWAVHeader(filePath string) (bool, error) {
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := parseWAV(file)
+	if err != nil {
+		return false, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	sizes := make([]byte, 4)
+	if _, err := file.ReadAt(sizes, 4); err != nil {
+		return false, err
+	}
+	riffEnd := int64(binary.LittleEndian.Uint32(sizes)) + 8
+	if _, err := file.ReadAt(sizes, info.DataOffset-4); err != nil {
+		return false, err
+	}
+	declared := int64(binary.LittleEndian.Uint32(sizes))
+
+	// Whole frames in the file, dropping a partially written one at the end
+	dataSize := stat.Size() - info.DataOffset
+	dataSize -= dataSize % int64(info.BlockAlign)
+
+	// A stale header declares less data than the file holds, in a data
+	// chunk that ends the RIFF
+	dataEnd := info.DataOffset + declared + declared&1
+	if declared == wavUnknownSize || declared >= dataSize || dataEnd < riffEnd {
+		return false, nil
+	}
+	binary.LittleEndian.PutUint32(sizes, uint32(info.DataOffset+dataSize-8))
+	if _, err := file.WriteAt(sizes, 4); err != nil {
+		return false, err
+	}
+	binary.LittleEndian.PutUint32(sizes, uint32(dataSize))
+	if _, err := file.WriteAt(sizes, info.DataOffset-4); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Write samples in [-1, 1] to a WAV file, replacing it only once the new
+// contents are complete so a failure leaves an existing file intact
+func writeWAVFile(filePath string, samples []float32, sampleRate, channels, bitsPerSample int) error {
+	return writeFileAtomic(filePath, func(file *os.File) error {
+		data := appendPCM(nil, samples, bitsPerSample)
+		if err := writeWAVHeader(file, sampleRate, channels, bitsPerSample, int64(len(data))); err != nil {
+			return err
+		}
+		_, err := file.Write(data)
+		return err
+	})
+}
+
+// Write a file through a temporary file next to it that is renamed into
+// place once complete, so a crash or quit never leaves a truncated file
+func writeFileAtomic(filePath string, write func(file *os.File) error) error {
+	tmpPath := filePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Sample size to rewrite this format with: float and 24-bit are kept,
+// anything else becomes 16-bit
+func (info *wavInfo) outputBits() int {
+	if info.FormatTag == wavFormatIEEEFloat {
+		return 32
+	}
+	if info.BitsPerSample == 24 {
+		return 24
+	}
+	return 16
+}
+
+// Append samples in [-1, 1] to dst in the encoding writeWAVHeader declares
+// for bitsPerSample: 16-bit or packed 24-bit PCM, or 32-bit float
+func appendPCM(dst []byte, samples []float32, bitsPerSample int) []byte {
+	for _, v := range samples {
+		switch bitsPerSample {
+		case 32:
+			dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(v))
+		case 24:
+			x := int32(math.Max(math.Min(float64(v)*8388607, 8388607), -8388608))
+			dst = append(dst, byte(x), byte(x>>8), byte(x>>16))
+		default:
+			dst = binary.LittleEndian.AppendUint16(dst, uint16(floatToInt16(float64(v))))
+		}
+	}
+	return dst
+}
+
+// Convert a sample in [-1, 1] to 16 bits, clipping anything outside
+func floatToInt16(v float64) int16 {
+	return int16(math.Max(math.Min(v*32767, 32767), -32768))
+}
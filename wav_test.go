@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// wavChunk is one RIFF chunk of a test fixture. size overrides the
+// declared size when non-zero.
+type wavChunk struct {
+	id   string
+	data []byte
+	size uint32
+}
+
+// Build a WAV file from chunks, padding odd-sized ones as RIFF requires
+func buildWAV(chunks ...wavChunk) []byte {
+	var body []byte
+	for _, c := range chunks {
+		size := c.size
+		if size == 0 {
+			size = uint32(len(c.data))
+		}
+		body = append(body, c.id...)
+		body = binary.LittleEndian.AppendUint32(body, size)
+		body = append(body, c.data...)
+		if len(c.data)%2 == 1 {
+			body = append(body, 0)
+		}
+	}
+	file := []byte("RIFF")
+	file = binary.LittleEndian.AppendUint32(file, uint32(4+len(body)))
+	file = append(file, "WAVE"...)
+	return append(file, body...)
+}
+
+// A 16-byte fmt chunk body
+func formatChunk(tag uint16, channels, sampleRate, bits int) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, tag)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate*channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*bits/8))
+	return binary.LittleEndian.AppendUint16(b, uint16(bits))
+}
+
+// A 40-byte WAVE_FORMAT_EXTENSIBLE fmt chunk body with the given subformat
+func extensibleChunk(subformat uint16, channels, sampleRate, bits int) []byte {
+	b := formatChunk(wavFormatExtensible, channels, sampleRate, bits)
+	b = binary.LittleEndian.AppendUint16(b, 22)           // Extension size
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits)) // Valid bits
+	b = binary.LittleEndian.AppendUint32(b, 3)            // Channel mask
+	b = binary.LittleEndian.AppendUint16(b, subformat)
+	return append(b, wavSubformatSuffix...)
+}
+
+func TestParseWAV(t *testing.T) {
+	pcm16 := formatChunk(wavFormatPCM, 2, 44100, 16)
+	frames := make([]byte, 16) // Four stereo 16-bit frames
+
+	tests := []struct {
+		name string
+		file []byte
+		want wavInfo
+	}{
+		{
+			name: "canonical",
+			file: buildWAV(wavChunk{id: "fmt ", data: pcm16}, wavChunk{id: "data", data: frames}),
+			want: wavInfo{FormatTag: wavFormatPCM, Channels: 2, SampleRate: 44100, BitsPerSample: 16, BlockAlign: 4, DataOffset: 44, DataSize: 16},
+		},
+		{
+			name: "odd-sized chunk before data",
+			file: buildWAV(wavChunk{id: "fmt ", data: pcm16}, wavChunk{id: "LIST", data: []byte("abc")}, wavChunk{id: "data", data: frames}),
+			want: wavInfo{FormatTag: wavFormatPCM, Channels: 2, SampleRate: 44100, BitsPerSample: 16, BlockAlign: 4, DataOffset: 56, DataSize: 16},
+		},
+		{
+			name: "data before fmt",
+			file: buildWAV(wavChunk{id: "data", data: frames}, wavChunk{id: "fmt ", data: pcm16}),
+			want: wavInfo{FormatTag: wavFormatPCM, Channels: 2, SampleRate: 44100, BitsPerSample: 16, BlockAlign: 4, DataOffset: 20, DataSize: 16},
+		},
+		{
+			name: "fmt chunk with extra bytes",
+			file: buildWAV(wavChunk{id: "fmt ", data: append(append([]byte{}, pcm16...), make([]byte, 100)...)}, wavChunk{id: "data", data: frames}),
+			want: wavInfo{FormatTag: wavFormatPCM, Channels: 2, SampleRate: 44100, BitsPerSample: 16, BlockAlign: 4, DataOffset: 144, DataSize: 16},
+		},
+		{
+			name: "truncated data",
+			file: buildWAV(wavChunk{id: "fmt ", data: pcm16}, wavChunk{id: "data", data: frames, size: 1000}),
+			want: wavInfo{FormatTag: wavFormatPCM, Channels: 2, SampleRate: 44100, BitsPerSample: 16, BlockAlign: 4, DataOffset: 44, DataSize: 16},
+		},
+		{
+			name: "unfinalized data",
+			file: buildWAV(wavChunk{id: "fmt ", data: pcm16}, wavChunk{id: "data", data: frames, size: wavUnknownSize}),
+			want: wavInfo{FormatTag: wavFormatPCM, Channels: 2, SampleRate: 44100, BitsPerSample: 16, BlockAlign: 4, DataOffset: 44, DataSize: 16},
+		},
+		{
+			name: "float",
+			file: buildWAV(wavChunk{id: "fmt ", data: formatChunk(wavFormatIEEEFloat, 1, 48000, 32)}, wavChunk{id: "data", data: frames}),
+			want: wavInfo{FormatTag: wavFormatIEEEFloat, Channels: 1, SampleRate: 48000, BitsPerSample: 32, BlockAlign: 4, DataOffset: 44, DataSize: 16},
+		},
+		{
+			name: "extensible float",
+			file: buildWAV(wavChunk{id: "fmt ", data: extensibleChunk(wavFormatIEEEFloat, 2, 48000, 32)}, wavChunk{id: "data", data: frames}),
+			want: wavInfo{FormatTag: wavFormatIEEEFloat, Channels: 2, SampleRate: 48000, BitsPerSample: 32, BlockAlign: 8, DataOffset: 68, DataSize: 16},
+		},
+		{
+			name: "extensible 24-bit PCM",
+			file: buildWAV(wavChunk{id: "fmt ", data: extensibleChunk(wavFormatPCM, 1, 96000, 24)}, wavChunk{id: "data", data: frames[:12]}),
+			want: wavInfo{FormatTag: wavFormatPCM, Channels: 1, SampleRate: 96000, BitsPerSample: 24, BlockAlign: 3, DataOffset: 68, DataSize: 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWAV(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("parseWAV: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseWAV = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWAVErrors(t *testing.T) {
+	pcm16 := formatChunk(wavFormatPCM, 2, 44100, 16)
+	frames := make([]byte, 16)
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"not RIFF", []byte("RIFX\x00\x00\x00\x00WAVE")},
+		{"empty", nil},
+		{"missing fmt", buildWAV(wavChunk{id: "data", data: frames})},
+		{"missing data", buildWAV(wavChunk{id: "fmt ", data: pcm16})},
+		{"short fmt", buildWAV(wavChunk{id: "fmt ", data: pcm16[:14]}, wavChunk{id: "data", data: frames})},
+		// A fmt chunk claiming nearly 4 GiB must fail, not allocate it
+		{"huge fmt", buildWAV(wavChunk{id: "fmt ", data: pcm16, size: 0xFFFFFFF0}, wavChunk{id: "data", data: frames})},
+		{"extensible too short", buildWAV(wavChunk{id: "fmt ", data: extensibleChunk(wavFormatPCM, 2, 44100, 16)[:24]}, wavChunk{id: "data", data: frames})},
+		{"zero channels", buildWAV(wavChunk{id: "fmt ", data: formatChunk(wavFormatPCM, 0, 44100, 16)}, wavChunk{id: "data", data: frames})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if info, err := parseWAV(bytes.NewReader(tt.file)); err == nil {
+				t.Errorf("parseWAV = %+v, want an error", info)
+			}
+		})
+	}
+}
+
+func TestParseWAVUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		fmt  []byte
+	}{
+		{"µ-law", formatChunk(0x0007, 1, 8000, 8)},
+		{"12-bit PCM", formatChunk(wavFormatPCM, 1, 8000, 12)},
+		{"64-bit float", formatChunk(wavFormatIEEEFloat, 1, 8000, 64)},
+		{"extensible ADPCM", extensibleChunk(0x0002, 1, 8000, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := buildWAV(wavChunk{id: "fmt ", data: tt.fmt}, wavChunk{id: "data", data: make([]byte, 8)})
+			_, err := parseWAV(bytes.NewReader(file))
+			var unsupported *UnsupportedWAVError
+			if !errors.As(err, &unsupported) {
+				t.Errorf("parseWAV error = %v, want an UnsupportedWAVError", err)
+			}
+		})
+	}
+}
+
+func TestWAVSampleDecoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  wavInfo
+		bytes []byte
+		want  float32
+	}{
+		{"8-bit", wavInfo{FormatTag: wavFormatPCM, BitsPerSample: 8}, []byte{0xC0}, 0.5},
+		{"16-bit", wavInfo{FormatTag: wavFormatPCM, BitsPerSample: 16}, []byte{0x00, 0xC0}, -0.5},
+		{"24-bit", wavInfo{FormatTag: wavFormatPCM, BitsPerSample: 24}, []byte{0x00, 0x00, 0x40}, 0.5},
+		{"32-bit PCM", wavInfo{FormatTag: wavFormatPCM, BitsPerSample: 32}, []byte{0x00, 0x00, 0x00, 0xC0}, -0.5},
+		{"float", wavInfo{FormatTag: wavFormatIEEEFloat, BitsPerSample: 32}, binary.LittleEndian.AppendUint32(nil, math.Float32bits(0.25)), 0.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.sampleFloat(tt.bytes); got != tt.want {
+				t.Errorf("sampleFloat = %v, want %v", got, tt.want)
+			}
+			want16 := floatToInt16(float64(tt.want))
+			if got := tt.info.sample16(tt.bytes); got < want16-1 || got > want16+1 {
+				t.Errorf("sample16 = %d, want %d", got, want16)
+			}
+		})
+	}
+}
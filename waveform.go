@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Live waveform settings
+const (
+	waveformSliceTime = 25 * time.Millisecond // Audio covered by one envelope slice
+	waveformHistory   = 2048                  // Slices kept for display
+	waveformRows      = 3                     // Braille rows, four dots each
+	waveformMinScale  = 0.1                   // Quietest peak the display zooms in to
+)
+
+// envelopeSlice is the sample range within one slice of time
+type envelopeSlice struct {
+	min float32
+	max float32
+}
+
+// envelopeBuilder cuts interleaved samples into envelope slices, carrying
+// a partial slice over to the next block
+type envelopeBuilder struct {
+	framesPerSlice int
+	channels       int
+	frames         int // Frames in the current slice so far
+	current        envelopeSlice
+}
+
+func newEnvelopeBuilder(sampleRate, channels int) *envelopeBuilder {
+	return &envelopeBuilder{
+		framesPerSlice: max(1, int(float64(sampleRate)*waveformSliceTime.Seconds())),
+		channels:       channels,
+	}
+}
+
+// Add a block of samples, appending each completed slice to slices. All
+// channels are folded into one envelope.
+func (b *envelopeBuilder) add(slices []envelopeSlice, samples []float32) []envelopeSlice {
+	for i := 0; i+b.channels <= len(samples); i += b.channels {
+		if b.frames == 0 {
+			b.current = envelopeSlice{min: samples[i], max: samples[i]}
+		}
+		for _, v := range samples[i : i+b.channels] {
+			if v < b.current.min {
+				b.current.min = v
+			}
+			if v > b.current.max {
+				b.current.max = v
+			}
+		}
+
+		b.frames++
+		if b.frames == b.framesPerSlice {
+			slices = append(slices, b.current)
+			b.frames = 0
+		}
+	}
+	return slices
+}
+
+// Append newly captured slices, keeping the most recent waveformHistory
+func (w *WaveformData) append(slices []envelopeSlice) {
+	w.slices = append(w.slices, slices...)
+	if len(w.slices) > waveformHistory {
+		w.slices = w.slices[len(w.slices)-waveformHistory:]
+	}
+}
+
+// Braille dot bits by dot row, for the left and right dot columns
+var (
+	brailleLeft  = [4]rune{0x01, 0x02, 0x04, 0x40}
+	brailleRight = [4]rune{0x08, 0x10, 0x20, 0x80}
+)
+
+// Render the most recent slices as rows of braille, two slices per
+// character, newest at the right. The vertical scale follows the loudest visible peak
+// so quiet speech is still readable.
+func renderWaveform(w WaveformData, width, rows int) []string {
+	if width <= 0 {
+		return nil
+	}
+	visible := w.slices[max(0, len(w.slices)-width*2):]
+
+	peak := waveformMinScale
+	for _, s := range visible {
+		peak = math.Max(peak, math.Max(math.Abs(float64(s.min)), math.Abs(float64(s.max))))
+	}
+
+	// Right-align so the waveform scrolls in from the right edge
+	offset := width*2 - len(visible)
+	dots := rows * 4
+	cells := make([][]rune, rows)
+	for row := range cells {
+		cells[row] = make([]rune, width)
+		for col := range cells[row] {
+			cells[row][col] = 0x2800
+		}
+	}
+
+	for i, s := range visible {
+		x := offset + i
+		bits := &brailleLeft
+		if x%2 == 1 {
+			bits = &brailleRight
+		}
+
+		// Dot rows span the range +peak at the top to -peak at the bottom
+		for dot := 0; dot < dots; dot++ {
+			top := peak * (1 - 2*float64(dot)/float64(dots))
+			bottom := peak * (1 - 2*float64(dot+1)/float64(dots))
+			if float64(s.max) >= bottom && float64(s.min) <= top {
+				cells[dot/4][x/2] |= bits[dot%4]
+			}
+		}
+	}
+
+	lines := make([]string, rows)
+	for row := range cells {
+		lines[row] = string(cells[row])
+	}
+	return lines
+}